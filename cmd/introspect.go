@@ -13,18 +13,23 @@ import (
 )
 
 func introspectCmd() *cobra.Command {
-	return &cobra.Command{
+	var dbtOpts dbt.Options
+
+	cmd := &cobra.Command{
 		Use:   "introspect",
 		Short: "Introspect your dal api schema",
 		Long:  "Introspects and prints the GraphQL schema for your dbt project.",
 		Run: func(cmd *cobra.Command, args []string) {
 			// Inspect the manifest and build a schema
-			dalSchema, client, err := dbt.Inspect()
+			dalSchema, client, report, err := dbt.Inspect(dbtOpts)
 			if err != nil {
 				log.Fatalf("ERROR loading dbt project: %v", err)
 			}
+			if !report.OK() {
+				log.Printf("WARNING dbt artifacts have drifted, some models or columns may be missing:\n%s", report)
+			}
 
-			gqlSchema, err := gql.BuildSchema(client, dalSchema)
+			gqlSchema, err := gql.BuildSchema(client, dalSchema, gql.Config{})
 			if err != nil {
 				log.Fatalf("ERROR creating schema: %v", err)
 			}
@@ -46,6 +51,10 @@ func introspectCmd() *cobra.Command {
 			}
 		},
 	}
+
+	dbtFlags(cmd, &dbtOpts)
+
+	return cmd
 }
 
 var introspectionQuery = `
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/supasheet/dal/internal/dbt"
+	"gopkg.in/yaml.v3"
 )
 
 type Cli struct {
@@ -23,3 +25,35 @@ func NewCli() *Cli {
 func (c *Cli) Execute() error {
 	return c.rootCmd.Execute()
 }
+
+// Adds the flags that pick out the dbt project, profile and target.
+func dbtFlags(cmd *cobra.Command, opts *dbt.Options) {
+	cmd.Flags().StringVar(&opts.ProjectDir, "project-dir", "", "Directory containing dbt_project.yml (default is the current directory)")
+	cmd.Flags().StringVar(&opts.ProfilesDir, "profiles-dir", "", "Directory containing profiles.yml (default is $DBT_PROFILES_DIR or ~/.dbt)")
+	cmd.Flags().StringVar(&opts.Profile, "profile", "", "Profile to use (default is the profile in dbt_project.yml)")
+	cmd.Flags().StringVar(&opts.Target, "target", "", "Target to use (default is $DBT_TARGET or the profile's target)")
+	cmd.Flags().StringVar(&opts.Artifacts, "artifacts", "", "Directory or HTTP URL to load manifest.json and catalog.json from (default is the project's target-path)")
+	cmd.Flags().BoolVar(&opts.InferTypes, "infer-types", false, "Look up column types in the warehouse when they're missing from catalog.json, or it is")
+	cmd.Flags().BoolVar(&opts.Verify, "verify", false, "Check the columns in catalog.json against the warehouse")
+	cmd.Flags().BoolVar(&opts.Strict, "strict", false, "Fail if the manifest, catalog and warehouse don't agree, rather than leaving out what doesn't")
+	cmd.Flags().Var(&varsValue{vars: &opts.Vars}, "vars", "Values for var() in dbt_project.yml and profiles.yml, as a YAML dictionary")
+}
+
+// A flag holding a YAML dictionary, the same as dbt's --vars.
+type varsValue struct {
+	vars *map[string]any
+	raw  string
+}
+
+func (v *varsValue) Set(s string) error {
+	var vars map[string]any
+	if err := yaml.Unmarshal([]byte(s), &vars); err != nil {
+		return err
+	}
+	*v.vars = vars
+	v.raw = s
+	return nil
+}
+
+func (v *varsValue) String() string { return v.raw }
+func (v *varsValue) Type() string   { return "yaml" }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"log"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/supasheet/dal/internal/dbt"
@@ -9,25 +10,38 @@ import (
 )
 
 func serveCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		queryTimeout time.Duration
+		maxRows      int
+		dbtOpts      dbt.Options
+	)
+
+	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Serve your dal api",
 		Long:  "Starts a graphql server that allows you to programatically access dbt models.",
 		Run: func(cmd *cobra.Command, args []string) {
 			// Inspect the manifest and build a schema
-			dalSchema, client, err := dbt.Inspect()
+			dalSchema, client, report, err := dbt.Inspect(dbtOpts)
 			if err != nil {
 				log.Fatalf("ERROR loading dbt project: %v", err)
 			}
+			if !report.OK() {
+				log.Printf("WARNING dbt artifacts have drifted, some models or columns may be missing:\n%s", report)
+			}
 
-			gqlSchema, err := gql.BuildSchema(client, dalSchema)
+			gqlSchema, err := gql.BuildSchema(client, dalSchema, gql.Config{QueryTimeout: queryTimeout, MaxRows: maxRows})
 			if err != nil {
 				log.Fatalf("ERROR creating schema: %v", err)
 			}
 
-			err = client.Connect()
-			if err != nil {
-				log.Fatalf("ERROR failed to connect to data warehouse: %v", err)
+			// Inferring types or verifying them means Inspect has connected
+			// already.
+			if !dbtOpts.InferTypes && !dbtOpts.Verify {
+				err = client.Connect()
+				if err != nil {
+					log.Fatalf("ERROR failed to connect to data warehouse: %v", err)
+				}
 			}
 
 			log.Print("Starting dal server on port 8080")
@@ -35,4 +49,10 @@ func serveCmd() *cobra.Command {
 			gql.Serve(gqlSchema)
 		},
 	}
+
+	dbtFlags(cmd, &dbtOpts)
+	cmd.Flags().DurationVar(&queryTimeout, "query-timeout", 0, "Cancel warehouse queries that run longer than this, e.g. 30s (0 means no timeout)")
+	cmd.Flags().IntVar(&maxRows, "max-rows", 100000, "The most rows a warehouse query may return, larger limits are rejected (0 means no limit)")
+
+	return cmd
 }
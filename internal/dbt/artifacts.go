@@ -0,0 +1,67 @@
+package dbt
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Artifacts are fetched with a timeout so a hung server doesn't hang startup.
+var artifactClient = &http.Client{Timeout: time.Minute}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// Where an artifact is, for error messages.
+func artifactPath(location, name string) string {
+	if !isURL(location) {
+		return filepath.Join(location, name)
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	u.Path = path.Join(u.Path, name)
+	return u.Redacted()
+}
+
+// Opens one of dbt's artifacts, e.g. manifest.json. The location is either a
+// directory, usually the project's target directory, or the HTTP URL of one,
+// such as where the docs site generated by dbt docs generate is hosted.
+func openArtifact(location, name string) (io.ReadCloser, error) {
+	if !isURL(location) {
+		path := filepath.Join(location, name)
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fileError(path, err)
+		}
+		return f, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, name)
+	resp, err := artifactClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err := errors.New(resp.Status)
+		if resp.StatusCode == http.StatusNotFound {
+			err = fs.ErrNotExist
+		}
+		return nil, &FileError{Path: u.Redacted(), Err: err}
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,56 @@
+package dbt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/internal/dbt"
+)
+
+const manifest = `{"metadata": {"dbt_schema_version": "https://schemas.getdbt.com/dbt/manifest/v12.json"}, "nodes": {"model.shop.orders": {
+	"resource_type": "model", "name": "orders", "unique_id": "model.shop.orders",
+	"config": {"meta": {"dal": {"expose": true}}}
+}}}`
+
+const catalog = `{"metadata": {"dbt_schema_version": "https://schemas.getdbt.com/dbt/catalog/v1.json"}, "nodes": {"model.shop.orders": {
+	"metadata": {"database": "DB", "schema": "MARTS", "name": "ORDERS"},
+	"columns": {"ID": {"type": "NUMBER", "name": "ID"}}
+}}}`
+
+func TestLoadArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "catalog.json"), []byte(catalog), 0o644))
+
+	srv := httptest.NewServer(http.StripPrefix("/docs", http.FileServer(http.Dir(dir))))
+	defer srv.Close()
+
+	for _, location := range []string{dir, srv.URL + "/docs", srv.URL + "/docs/"} {
+		t.Run(location, func(t *testing.T) {
+			m, err := dbt.LoadManifest(location)
+			require.NoError(t, err)
+			require.Len(t, m.Nodes, 1)
+			assert.Equal(t, "orders", m.Nodes[0].Name)
+
+			c, err := dbt.LoadCatalog(location)
+			require.NoError(t, err)
+			assert.Equal(t, "ORDERS", c.Nodes["model.shop.orders"].Metadata.Name)
+		})
+	}
+}
+
+func TestLoadProject_TargetPath(t *testing.T) {
+	dir := t.TempDir()
+	project := "name: shop\nprofile: shop\ntarget-path: build\nrequire-dbt-version: '>=1.0.0'\nmodels:\n  shop:\n    +materialized: view\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dbt_project.yml"), []byte(project), 0o644))
+
+	p, err := dbt.LoadProject(dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "shop", p.Profile)
+	assert.Equal(t, "build", p.TargetPath)
+}
@@ -1,59 +1,98 @@
 package dbt
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"io"
 	"strings"
-	"time"
-
-	"gopkg.in/yaml.v2"
 )
 
-func LoadCatalog() *Catalog {
-	f, err := os.Open(filepath.Join("target", "catalog.json"))
+// Loads the catalog from the given artifacts location, see openArtifact.
+func LoadCatalog(location string) (*Catalog, error) {
+	f, err := openArtifact(location, "catalog.json")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer f.Close()
 
-	var c Catalog
-	err = yaml.NewDecoder(f).Decode(&c)
+	c, err := ParseCatalog(f)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fileError(artifactPath(location, "catalog.json"), err)
 	}
+	return c, nil
+}
 
-	return &c
+// Reads a catalog, checking it's a version dal understands.
+func ParseCatalog(r io.Reader) (*Catalog, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var c Catalog
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, jsonError(b, fmt.Errorf("invalid dbt catalog: %w", err))
+	}
+
+	version, err := schemaVersion("catalog", c.Metadata.DbtSchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	if version != catalogVersion {
+		return nil, fmt.Errorf(
+			"catalog v%d (dbt %s) is not supported, dal supports v%d",
+			version, c.Metadata.DbtVersion, catalogVersion,
+		)
+	}
+	c.index()
+	return &c, nil
 }
 
 // v1 DBT Catalog https://schemas.getdbt.com/dbt/catalog/v1.json
 type Catalog struct {
-	Metadata CatalogMetadata        `json:"metadata"`
+	Metadata ArtifactMetadata       `json:"metadata"`
 	Nodes    map[string]CatalogNode `json:"nodes"`
+
+	// The lower cased names of each node's columns, mapped to the names as
+	// the warehouse reports them.
+	columns map[string]map[string]string
 }
 
-func (c *Catalog) typeOfColumn(uniqueId, column string) (string, error) {
-	for key, node := range c.Nodes {
-		if key == uniqueId {
-			for name, col := range node.Columns {
-				if strings.ToLower(name) == strings.ToLower(column) {
-					return col.Type, nil
-				}
-			}
-			return "", fmt.Errorf("column %s not found on model %s", column, uniqueId)
+// Indexes the column names, so looking up every column of a large project
+// doesn't mean scanning every other column for each one.
+func (c *Catalog) index() {
+	c.columns = make(map[string]map[string]string, len(c.Nodes))
+	for id, node := range c.Nodes {
+		names := make(map[string]string, len(node.Columns))
+		for name := range node.Columns {
+			names[strings.ToLower(name)] = name
 		}
+		c.columns[id] = names
 	}
-	return "", fmt.Errorf("model %s not in dbt catalog", uniqueId)
 }
 
-type CatalogMetadata struct {
-	DbtSchemaVersion string    `json:"dbt_schema_version"`
-	DbtVersion       string    `json:"dbt_version"`
-	GeneratedAt      time.Time `json:"generated_at"`
-	InvocationID     string    `json:"invocation_id"`
-	Env              struct {
-	} `json:"env"`
+// Looks up where a model lives, as the warehouse reports it.
+func (c *Catalog) lookupRelation(uniqueId string) (CatalogNodeMetadata, bool) {
+	node, ok := c.Nodes[uniqueId]
+	return node.Metadata, ok
+}
+
+// Looks up a column of a model. The match on the column name is case
+// insensitive, the returned column has the name as the warehouse reports it.
+func (c *Catalog) lookupColumn(uniqueId, column string) (CatalogNodeColumns, error) {
+	if c.columns == nil {
+		c.index()
+	}
+	node, ok := c.Nodes[uniqueId]
+	if !ok {
+		return CatalogNodeColumns{}, fmt.Errorf("model %s not in dbt catalog", uniqueId)
+	}
+	name, ok := c.columns[uniqueId][strings.ToLower(column)]
+	if !ok {
+		return CatalogNodeColumns{}, fmt.Errorf("column %s not found on model %s", column, uniqueId)
+	}
+	col := node.Columns[name]
+	col.Name = name
+	return col, nil
 }
 
 type CatalogNode struct {
@@ -82,7 +121,7 @@ type CatalogNodeColumns struct {
 type CatalogNodeStats struct {
 	ID          string `json:"id"`
 	Label       string `json:"label"`
-	Value       string `json:"value"`
+	Value       any    `json:"value"`
 	Include     bool   `json:"include"`
 	Description string `json:"description"`
 }
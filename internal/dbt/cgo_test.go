@@ -0,0 +1,6 @@
+//go:build cgo
+
+package dbt_test
+
+// The test projects run against DuckDB, which needs cgo.
+const haveDuckDB = true
@@ -0,0 +1,133 @@
+package dbt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+var (
+	// Neither the options nor dbt_project.yml name a profile.
+	ErrNoProfile = errors.New("no profile given, set profile in dbt_project.yml or pass --profile")
+	// A profile has no target to use.
+	ErrNoTarget = errors.New("no target given, set target in the profile or pass --target")
+)
+
+// An error in one of the dbt project's files or artifacts. Line and Column
+// are from 1, and zero when they aren't known.
+type FileError struct {
+	Path   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *FileError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+	default:
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+}
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+// Attaches the path to an error from reading a file. Errors that already know
+// where in the file they're from keep that.
+func fileError(path string, err error) error {
+	var fe *FileError
+	if errors.As(err, &fe) {
+		fe.Path = path
+		return fe
+	}
+	// The path is already in the message of these.
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &FileError{Path: path, Err: err}
+}
+
+// Works out where in a JSON document an error from decoding it is.
+func jsonError(b []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	before := b[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return &FileError{Line: line, Column: column, Err: err}
+}
+
+// The profile isn't in profiles.yml.
+type ProfileNotFoundError struct {
+	Profile   string
+	Path      string
+	Available []string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	return fmt.Sprintf("profile %s not found in %s, it has %s", e.Profile, e.Path, list(e.Available))
+}
+
+// The target isn't one of the profile's outputs.
+type TargetNotFoundError struct {
+	Profile   string
+	Target    string
+	Available []string
+}
+
+func (e *TargetNotFoundError) Error() string {
+	return fmt.Sprintf("target %s not found in profile %s, it has %s", e.Target, e.Profile, list(e.Available))
+}
+
+// A model's meta.dal config doesn't make sense.
+type MetaError struct {
+	Model string
+	// The file the model's config is from.
+	Path string
+	Err  error
+}
+
+func (e *MetaError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid meta.dal for model %s: %v", e.Model, e.Err)
+	}
+	return fmt.Sprintf("invalid meta.dal for model %s in %s: %v", e.Model, e.Path, e.Err)
+}
+
+func (e *MetaError) Unwrap() error { return e.Err }
+
+func keys[V any](m map[string]V) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func list(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,133 @@
+package dbt_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/internal/dbt"
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+func TestLoadProfile_Errors(t *testing.T) {
+	dir := writeProfile(t, "password: \"{{ env_var('DAL_TEST_UNSET') }}\"")
+
+	_, err := dbt.LoadProfile(dir, "", nil)
+	assert.ErrorIs(t, err, dbt.ErrNoProfile)
+
+	_, err = dbt.LoadProfile(dir, "other", nil)
+	var notFound *dbt.ProfileNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, []string{"test"}, notFound.Available)
+	assert.EqualError(t, err, "profile other not found in "+filepath.Join(dir, "profiles.yml")+", it has test")
+
+	_, err = dbt.LoadProfile(dir, "test", nil)
+	var fileErr *dbt.FileError
+	require.ErrorAs(t, err, &fileErr)
+	assert.Equal(t, filepath.Join(dir, "profiles.yml"), fileErr.Path)
+	assert.Equal(t, 6, fileErr.Line)
+	assert.Contains(t, err.Error(), "env var required but not provided: DAL_TEST_UNSET")
+
+	_, err = dbt.LoadProfile(t.TempDir(), "test", nil)
+	require.ErrorAs(t, err, &fileErr)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestInspect_Errors(t *testing.T) {
+	setDal := func(node string, config map[string]any) func(map[string]any) {
+		return func(m map[string]any) {
+			dig(m, "nodes", node, "config", "meta")["dal"] = config
+		}
+	}
+	cases := []struct {
+		name   string
+		opts   func(*dbt.Options)
+		edit   func(map[string]any)
+		target any
+		err    string
+	}{
+		{
+			name:   "missing target",
+			opts:   func(o *dbt.Options) { o.Target = "prod" },
+			target: new(*dbt.TargetNotFoundError),
+			err:    "target prod not found in profile shop, it has dev",
+		},
+		{
+			name:   "malformed meta",
+			edit:   setDal("model.shop.model_0", map[string]any{"expose": "yes"}),
+			target: new(*dbt.MetaError),
+			err:    "invalid meta.dal for model model_0: expose should be a bool, not a string",
+		},
+		{
+			name: "foreign key without a primary key",
+			edit: setDal("model.shop.model_0", map[string]any{
+				"expose": true, "foreign_keys": []any{map[string]any{"model": "model_1", "right_on": "column_0"}},
+			}),
+			target: new(*dbt.MetaError),
+			err:    "invalid meta.dal for model model_0: primary_key is required for foreign_keys",
+		},
+		{
+			name: "foreign key without right_on",
+			edit: setDal("model.shop.model_0", map[string]any{
+				"expose": true, "primary_key": "column_0", "foreign_keys": []any{map[string]any{"model": "model_1"}},
+			}),
+			target: new(*dbt.MetaError),
+			err:    "invalid meta.dal for model model_0: foreign key 1 needs a model and right_on",
+		},
+		{
+			name: "foreign key to an unknown model",
+			edit: func(m map[string]any) {
+				dig(m, "nodes", "model.shop.model_0")["patch_path"] = "shop://models/schema.yml"
+				setDal("model.shop.model_0", map[string]any{
+					"expose": true, "primary_key": "column_0", "foreign_keys": []any{map[string]any{"model": "nope", "right_on": "column_0"}},
+				})(m)
+			},
+			target: new(*dbt.MetaError),
+			err:    "invalid meta.dal for model model_0 in models/schema.yml: cannot create foreign key: nope is not a valid model: no such model",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := writeProject(t, 2, 1)
+			if c.opts != nil {
+				c.opts(&opts)
+			}
+			if c.edit != nil {
+				editArtifact(t, opts, "manifest.json", c.edit)
+			}
+			_, _, _, err := dbt.Inspect(opts)
+			require.Error(t, err)
+			assert.True(t, errors.As(err, c.target), "%T is not %T", err, c.target)
+			assert.Contains(t, err.Error(), c.err)
+		})
+	}
+}
+
+func TestInspect_UnknownModelIsNoSuchModel(t *testing.T) {
+	opts := writeProject(t, 1, 1)
+	editArtifact(t, opts, "manifest.json", func(m map[string]any) {
+		dig(m, "nodes", "model.shop.model_0", "config", "meta")["dal"] = map[string]any{
+			"expose": true, "primary_key": "column_0", "foreign_keys": []any{map[string]any{"model": "nope", "right_on": "x"}},
+		}
+	})
+	_, _, _, err := dbt.Inspect(opts)
+	assert.ErrorIs(t, err, dal.ErrNoSuchModel)
+}
+
+func TestLoadManifest_SyntaxError(t *testing.T) {
+	dir := t.TempDir()
+	manifest := "{\n  \"metadata\": {},\n  \"nodes\": {,}\n}"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0o644))
+
+	_, err := dbt.LoadManifest(dir)
+	var fileErr *dbt.FileError
+	require.ErrorAs(t, err, &fileErr)
+	assert.Equal(t, filepath.Join(dir, "manifest.json"), fileErr.Path)
+	assert.Equal(t, 3, fileErr.Line)
+	assert.True(t, strings.HasPrefix(err.Error(), filepath.Join(dir, "manifest.json")+":3:"), err.Error())
+}
@@ -3,66 +3,207 @@ package dbt
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"path/filepath"
 
-	"github.com/supasheet/dal/internal/dal"
-	"github.com/supasheet/dal/internal/warehouse"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
 )
 
 // Inspects a dbt project and builds a dal schema and a warehouse client.
-func Inspect() (dal.Schema, warehouse.Client, error) {
-	project := LoadProject()
-	profile := project.LoadProfile()
-	// Just load the default target
-	target := profile.Outputs[profile.Target]
-
-	// First let's setup the warehouse connection
-	var client warehouse.Client
-	if target.Type == "snowflake" {
-		client = warehouse.NewSnowflake(
-			warehouse.SnowflakeCredentials{
-				AccountId: target.Account,
-				User:      target.User,
-				Password:  target.Password,
-				Database:  target.Database,
-				Schema:    target.Schema,
-				Warehouse: target.Warehouse,
-			},
-		)
-	} else {
-		// We don't support that kind of warehouse, so return an error.
-		return nil, nil, errors.New(fmt.Sprintf("warehouse type %s is not supported", target.Type))
+// Models and columns whose artifacts don't agree are left out of the schema
+// rather than failing, and described in the report. With opts.Strict they're
+// an ErrDrift instead, but the report is returned all the same.
+func Inspect(opts Options) (dal.Schema, warehouse.Client, *Report, error) {
+	// The project is only needed for its profile and target-path, so it can
+	// be done without when both of those are given.
+	project := &Project{}
+	if opts.Artifacts == "" || opts.Profile == "" {
+		var err error
+		project, err = LoadProject(opts.projectDir(), opts.Vars)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	profileName := opts.Profile
+	if profileName == "" {
+		profileName = project.Profile
+	}
+	profilesDir, err := opts.profilesDir()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	profile, err := LoadProfile(profilesDir, profileName, opts.Vars)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	targetName := opts.target(profile)
+	if targetName == "" {
+		return nil, nil, nil, ErrNoTarget
+	}
+	target, ok := profile.Outputs[targetName]
+	if !ok {
+		return nil, nil, nil, &TargetNotFoundError{Profile: profileName, Target: targetName, Available: keys(profile.Outputs)}
+	}
+
+	// First let's setup the warehouse connection, using whichever adapter
+	// has registered itself for the target's type.
+	client, err := warehouse.New(target.Type(), target)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	// Now we can load up the manifest and try to build a dal schema from it.
-	nodes := LoadManifestNodes()
-	catalog := LoadCatalog()
+	artifacts := opts.Artifacts
+	if artifacts == "" {
+		artifacts = project.targetPath()
+		if !filepath.IsAbs(artifacts) {
+			artifacts = filepath.Join(opts.projectDir(), artifacts)
+		}
+	}
+	manifest, err := LoadManifest(artifacts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	report := &Report{Manifest: manifest.Metadata, Verified: opts.Verify}
+	catalog, err := LoadCatalog(artifacts)
+	switch {
+	case err == nil:
+		report.Catalog = &catalog.Metadata
+	case opts.InferTypes && errors.Is(err, fs.ErrNotExist):
+		catalog = &Catalog{}
+	default:
+		return nil, nil, nil, err
+	}
+
+	// Anything missing from the catalog is looked up in the warehouse, if
+	// we've been asked to, and everything else is checked against it.
+	var live *liveColumns
+	if opts.InferTypes || opts.Verify {
+		if err := client.Connect(); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to connect to data warehouse: %w", err)
+		}
+		live = newLiveColumns(client)
+	}
+
 	schema := make(dal.Schema)
 
 	// First up create all of the nodes
-	for _, node := range nodes {
+	for _, node := range manifest.Nodes {
+		_, inCatalog := catalog.lookupRelation(node.UniqueID)
+		if !inCatalog && report.Catalog != nil {
+			if live == nil {
+				report.add(Drift{Model: node.Name, Reason: NotInCatalog})
+				continue
+			}
+			report.add(Drift{Model: node.Name, Reason: Inferred})
+		}
+
 		// Add the model
 		model := schema.AddModel(node.Name, node.Description, node.Config.Meta.Dal.PrimaryKey)
+		model.Relation = relationOf(node, catalog, client.Dialect())
 		for _, col := range node.Columns {
 			// Before creating the column we need to look up the appropriate
-			// type for it from the schema.
-			colType, err := catalog.typeOfColumn(node.UniqueID, col.Name)
+			// type for it, along with the name the warehouse really knows it
+			// by.
+			catCol, ok, err := resolveColumn(node, model, col, catalog, live, opts.Verify, report)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
-			model.AddColumn(col.Name, col.Description, client.MapType(colType))
+			if ok {
+				model.AddColumn(col.Name, catCol.Name, col.Description, client.MapType(catCol.Type))
+			}
+		}
+
+		// A model without any columns can't be queried.
+		if len(model.Columns) == 0 {
+			delete(schema, node.Name)
 		}
 	}
 
 	// Then go through and make all the foreign keys
-	for _, node := range nodes {
+	for _, node := range manifest.Nodes {
 		node := node
-		model := schema[node.Name]
+		model, ok := schema[node.Name]
+		if !ok {
+			continue
+		}
 		for _, fk := range node.Config.Meta.Dal.ForeignKeys {
-			if err := model.AddForeignKey(fk.Model, fk.RightOn); err != nil {
-				return nil, nil, err
+			// Models that drifted away have already been reported.
+			if !manifest.exposes(fk.Model) || schema[fk.Model] != nil {
+				if err := model.AddForeignKey(fk.Model, fk.RightOn); err != nil {
+					return nil, nil, nil, &MetaError{Model: node.Name, Path: node.configPath(), Err: err}
+				}
 			}
 		}
 	}
 
-	return schema, client, nil
+	if opts.Strict && !report.OK() {
+		return nil, nil, report, fmt.Errorf("%w:\n%s", ErrDrift, report)
+	}
+	return schema, client, report, nil
+}
+
+// Finds the type and warehouse name of a column. ok is false, and the drift
+// reported, when it can't be found.
+func resolveColumn(node Node, model *dal.Model, col Column, catalog *Catalog, live *liveColumns, verify bool, report *Report) (CatalogNodeColumns, bool, error) {
+	catCol, err := catalog.lookupColumn(node.UniqueID, col.Name)
+	if err != nil {
+		if live == nil {
+			report.add(Drift{Model: node.Name, Column: col.Name, Reason: NotInCatalog})
+			return CatalogNodeColumns{}, false, nil
+		}
+		liveCol, ok, err := live.lookupColumn(node.UniqueID, model.Relation, col.Name)
+		if err != nil {
+			return CatalogNodeColumns{}, false, err
+		}
+		if !ok {
+			report.add(Drift{Model: node.Name, Column: col.Name, Reason: NotInWarehouse, Detail: "or the catalog"})
+			return CatalogNodeColumns{}, false, nil
+		}
+		// Only worth mentioning if the catalog should have had it.
+		if _, inCatalog := catalog.lookupRelation(node.UniqueID); inCatalog {
+			report.add(Drift{Model: node.Name, Column: col.Name, Reason: Inferred})
+		}
+		return liveCol, true, nil
+	}
+	if !verify {
+		return catCol, true, nil
+	}
+
+	liveCol, ok, err := live.lookupColumn(node.UniqueID, model.Relation, col.Name)
+	if err != nil {
+		return CatalogNodeColumns{}, false, err
+	}
+	if !ok {
+		report.add(Drift{Model: node.Name, Column: col.Name, Reason: NotInWarehouse})
+		return CatalogNodeColumns{}, false, nil
+	}
+	if live.client.MapType(liveCol.Type) != live.client.MapType(catCol.Type) {
+		report.add(Drift{
+			Model: node.Name, Column: col.Name, Reason: TypeMismatch,
+			Detail: fmt.Sprintf("%s in the catalog, %s in the warehouse", catCol.Type, liveCol.Type),
+		})
+	}
+	return liveCol, true, nil
+}
+
+// Works out where a model lives. The catalog has the names exactly as the
+// warehouse reports them, so it's preferred. Otherwise the manifest has what
+// dbt was configured with, which dbt doesn't quote by default, so they're
+// folded the way the warehouse would have.
+func relationOf(node Node, catalog *Catalog, dialect string) dal.Relation {
+	if meta, ok := catalog.lookupRelation(node.UniqueID); ok {
+		return dal.Relation{Database: meta.Database, Schema: meta.Schema, Identifier: meta.Name}
+	}
+
+	identifier := node.Alias
+	if identifier == "" {
+		identifier = node.Name
+	}
+	return dal.Relation{
+		Database:   warehouse.Fold(dialect, node.Database),
+		Schema:     warehouse.Fold(dialect, node.Schema),
+		Identifier: warehouse.Fold(dialect, identifier),
+	}
 }
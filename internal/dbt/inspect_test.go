@@ -0,0 +1,247 @@
+package dbt_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/internal/dbt"
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+// Writes a dbt project with the given number of exposed models, each with the
+// given number of columns, and returns the options to inspect it with. The
+// catalog reports column names upper cased, the way Snowflake does.
+func writeProject(tb testing.TB, models, columns int) dbt.Options {
+	if !haveDuckDB {
+		tb.Skip("the test projects run against DuckDB, which needs cgo")
+	}
+	dir := tb.TempDir()
+	write := func(name string, v any) {
+		var b []byte
+		switch v := v.(type) {
+		case string:
+			b = []byte(v)
+		default:
+			var err error
+			b, err = json.Marshal(v)
+			require.NoError(tb, err)
+		}
+		require.NoError(tb, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		require.NoError(tb, os.WriteFile(filepath.Join(dir, name), b, 0o644))
+	}
+
+	write("dbt_project.yml", "name: shop\nprofile: shop\n")
+	write("profiles.yml", "shop:\n  target: dev\n  outputs:\n    dev:\n      type: duckdb\n      path: ':memory:'\n")
+
+	manifestNodes := map[string]any{}
+	catalogNodes := map[string]any{}
+	for m := 0; m < models; m++ {
+		id := fmt.Sprintf("model.shop.model_%d", m)
+		manifestCols := map[string]any{}
+		catalogCols := map[string]any{}
+		for c := 0; c < columns; c++ {
+			name := fmt.Sprintf("column_%d", c)
+			manifestCols[name] = map[string]any{"name": name}
+			catalogCols[strings.ToUpper(name)] = map[string]any{"name": strings.ToUpper(name), "type": "INTEGER", "index": c}
+		}
+		manifestNodes[id] = map[string]any{
+			"resource_type": "model",
+			"unique_id":     id,
+			"name":          fmt.Sprintf("model_%d", m),
+			"database":      "db",
+			"schema":        "main",
+			"columns":       manifestCols,
+			"config":        map[string]any{"meta": map[string]any{"dal": map[string]any{"expose": true}}},
+		}
+		catalogNodes[id] = map[string]any{
+			"unique_id": id,
+			"metadata":  map[string]any{"database": "db", "schema": "main", "name": fmt.Sprintf("model_%d", m)},
+			"columns":   catalogCols,
+		}
+	}
+	write("target/manifest.json", map[string]any{
+		"metadata": map[string]any{"dbt_schema_version": "https://schemas.getdbt.com/dbt/manifest/v12.json"},
+		"nodes":    manifestNodes,
+	})
+	write("target/catalog.json", map[string]any{
+		"metadata": map[string]any{"dbt_schema_version": "https://schemas.getdbt.com/dbt/catalog/v1.json"},
+		"nodes":    catalogNodes,
+	})
+
+	return dbt.Options{ProjectDir: dir, ProfilesDir: dir}
+}
+
+// Points the project at a DuckDB warehouse, set up with the given SQL.
+func writeWarehouse(t *testing.T, opts dbt.Options, setup string) {
+	path := filepath.Join(opts.ProjectDir, "db.duckdb")
+	db, err := sql.Open("duckdb", path)
+	require.NoError(t, err)
+	_, err = db.ExecContext(context.Background(), setup)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	profiles := "shop:\n  target: dev\n  outputs:\n    dev:\n      type: duckdb\n      path: " + path + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(opts.ProfilesDir, "profiles.yml"), []byte(profiles), 0o644))
+}
+
+// Rewrites one of the project's artifacts.
+func editArtifact(t *testing.T, opts dbt.Options, name string, edit func(artifact map[string]any)) {
+	path := filepath.Join(opts.ProjectDir, "target", name)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var artifact map[string]any
+	require.NoError(t, json.Unmarshal(b, &artifact))
+	edit(artifact)
+	b, err = json.Marshal(artifact)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, b, 0o644))
+}
+
+// Gets hold of a nested value of an artifact.
+func dig(artifact map[string]any, keys ...string) map[string]any {
+	for _, key := range keys {
+		artifact = artifact[key].(map[string]any)
+	}
+	return artifact
+}
+
+func TestInspect(t *testing.T) {
+	schema, _, report, err := dbt.Inspect(writeProject(t, 2, 3))
+	require.NoError(t, err)
+	require.Len(t, schema, 2)
+	assert.True(t, report.OK())
+
+	model := schema["model_1"]
+	assert.Equal(t, dal.Relation{Database: "db", Schema: "main", Identifier: "model_1"}, model.Relation)
+	col, ok := model.Column("column_2")
+	require.True(t, ok)
+	assert.Equal(t, "COLUMN_2", col.Identifier)
+	assert.Equal(t, dal.Int, col.Type)
+}
+
+// Startup time for a project the size of a large dbt deployment.
+func BenchmarkInspect(b *testing.B) {
+	opts := writeProject(b, 3000, 30)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := dbt.Inspect(opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestInspect_InferTypes(t *testing.T) {
+	opts := writeProject(t, 1, 3)
+	// A warehouse where model_0 has a column the catalog doesn't know about.
+	writeWarehouse(t, opts, "create table main.model_0 (column_0 integer, column_1 integer, column_2 varchar)")
+	editArtifact(t, opts, "catalog.json", func(c map[string]any) {
+		delete(dig(c, "nodes", "model.shop.model_0", "columns"), "COLUMN_2")
+	})
+
+	// Without inferring types the column is left out.
+	schema, _, report, err := dbt.Inspect(opts)
+	require.NoError(t, err)
+	_, ok := schema["model_0"].Column("column_2")
+	assert.False(t, ok)
+	assert.Equal(t, []dbt.Drift{{Model: "model_0", Column: "column_2", Reason: dbt.NotInCatalog}}, report.Drift)
+
+	opts.InferTypes = true
+	schema, client, report, err := dbt.Inspect(opts)
+	require.NoError(t, err)
+	col, _ := schema["model_0"].Column("column_2")
+	assert.Equal(t, dal.String, col.Type)
+	assert.Equal(t, "column_2", col.Identifier)
+	assert.Equal(t, []dbt.Drift{{Model: "model_0", Column: "column_2", Reason: dbt.Inferred}}, report.Drift)
+	// The catalog is still used for what it has.
+	col, _ = schema["model_0"].Column("column_0")
+	assert.Equal(t, "COLUMN_0", col.Identifier)
+
+	// Without a catalog at all.
+	require.NoError(t, os.Remove(filepath.Join(opts.ProjectDir, "target", "catalog.json")))
+	schema, _, report, err = dbt.Inspect(opts)
+	require.NoError(t, err)
+	assert.Nil(t, report.Catalog)
+	assert.Empty(t, report.Drift)
+	col, _ = schema["model_0"].Column("column_0")
+	assert.Equal(t, dal.Int, col.Type)
+	assert.Equal(t, "column_0", col.Identifier)
+
+	// The client is left connected, ready to serve queries.
+	rs, err := client.Run(context.Background(), "select count(*) as n from main.model_0")
+	require.NoError(t, err)
+	assert.Len(t, rs, 1)
+}
+
+func TestInspect_StaleCatalog(t *testing.T) {
+	opts := writeProject(t, 3, 2)
+	editArtifact(t, opts, "manifest.json", func(m map[string]any) {
+		dig(m, "metadata")["generated_at"] = "2024-03-02T12:00:00Z"
+		dig(m, "metadata")["invocation_id"] = "new"
+		// model_2 has a foreign key to model_1, which drifts away.
+		dig(m, "nodes", "model.shop.model_2", "config", "meta", "dal")["primary_key"] = "column_0"
+		dig(m, "nodes", "model.shop.model_2", "config", "meta", "dal")["foreign_keys"] = []any{
+			map[string]any{"model": "model_1", "right_on": "column_0"},
+		}
+	})
+	editArtifact(t, opts, "catalog.json", func(c map[string]any) {
+		dig(c, "metadata")["generated_at"] = "2024-03-01T12:00:00Z"
+		dig(c, "metadata")["invocation_id"] = "old"
+		delete(dig(c, "nodes"), "model.shop.model_1")
+		delete(dig(c, "nodes", "model.shop.model_0", "columns"), "COLUMN_1")
+	})
+
+	schema, _, report, err := dbt.Inspect(opts)
+	require.NoError(t, err)
+	assert.True(t, report.Stale())
+	assert.False(t, report.OK())
+	assert.ElementsMatch(t, []dbt.Drift{
+		{Model: "model_1", Reason: dbt.NotInCatalog},
+		{Model: "model_0", Column: "column_1", Reason: dbt.NotInCatalog},
+	}, report.Drift)
+
+	// What drifted is left out, the rest is served.
+	assert.NotContains(t, schema, "model_1")
+	assert.Empty(t, schema["model_2"].ForeignKeys)
+	assert.Len(t, schema["model_0"].Columns, 1)
+
+	assert.Contains(t, report.String(), "the catalog is 24h0m0s older than the manifest, run dbt docs generate to refresh it")
+	assert.Contains(t, report.String(), "model_0.column_1: not in the catalog")
+
+	opts.Strict = true
+	_, _, report, err = dbt.Inspect(opts)
+	assert.ErrorIs(t, err, dbt.ErrDrift)
+	assert.NotNil(t, report)
+}
+
+func TestInspect_Verify(t *testing.T) {
+	opts := writeProject(t, 2, 3)
+	// model_0 has changed since the catalog was generated, and model_1 has
+	// gone altogether.
+	writeWarehouse(t, opts, "create table main.model_0 (column_0 varchar, column_1 integer)")
+	opts.Verify = true
+
+	schema, _, report, err := dbt.Inspect(opts)
+	require.NoError(t, err)
+	assert.True(t, report.Verified)
+	assert.ElementsMatch(t, []dbt.Drift{
+		{Model: "model_0", Column: "column_0", Reason: dbt.TypeMismatch, Detail: "INTEGER in the catalog, VARCHAR in the warehouse"},
+		{Model: "model_0", Column: "column_2", Reason: dbt.NotInWarehouse},
+		{Model: "model_1", Column: "column_0", Reason: dbt.NotInWarehouse},
+		{Model: "model_1", Column: "column_1", Reason: dbt.NotInWarehouse},
+		{Model: "model_1", Column: "column_2", Reason: dbt.NotInWarehouse},
+	}, report.Drift)
+
+	// The warehouse wins.
+	col, _ := schema["model_0"].Column("column_0")
+	assert.Equal(t, dal.String, col.Type)
+	assert.Len(t, schema["model_0"].Columns, 2)
+	assert.NotContains(t, schema, "model_1")
+}
@@ -0,0 +1,43 @@
+package dbt
+
+import (
+	"context"
+	"strings"
+
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+// Looks up columns in the warehouse itself, for when the catalog is missing
+// or out of date. Each model's columns are fetched once, the first time one of
+// them is needed.
+type liveColumns struct {
+	client warehouse.Client
+	// By model, the lower cased column names mapped to the columns as the
+	// warehouse reports them.
+	models map[string]map[string]CatalogNodeColumns
+}
+
+func newLiveColumns(client warehouse.Client) *liveColumns {
+	return &liveColumns{client: client, models: make(map[string]map[string]CatalogNodeColumns)}
+}
+
+// Looks up a column, case insensitively. ok is false if the warehouse doesn't
+// have it.
+func (lc *liveColumns) lookupColumn(uniqueId string, rel dal.Relation, column string) (CatalogNodeColumns, bool, error) {
+	columns, ok := lc.models[uniqueId]
+	if !ok {
+		types, err := warehouse.Columns(context.Background(), lc.client, rel)
+		if err != nil {
+			return CatalogNodeColumns{}, false, err
+		}
+		columns = make(map[string]CatalogNodeColumns, len(types))
+		for name, t := range types {
+			columns[strings.ToLower(name)] = CatalogNodeColumns{Name: name, Type: t}
+		}
+		lc.models[uniqueId] = columns
+	}
+
+	col, ok := columns[strings.ToLower(column)]
+	return col, ok, nil
+}
@@ -0,0 +1,394 @@
+package dbt
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Renders the Jinja dbt allows in its config files, which is a small subset
+// of it: env_var() with an optional default, var() with an optional default,
+// the as_bool, as_number, as_native and as_text filters, and ~ to join strings together.
+// Like dbt, values are rendered after the YAML is parsed, so the rendered text
+// can't break the YAML.
+type renderer struct {
+	vars map[string]any
+}
+
+// Decodes the YAML in b into v, rendering any Jinja in its values first.
+func (r renderer) decode(b []byte, v any) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	return r.decodeNode(&doc, v)
+}
+
+// Decodes the YAML mapping in b into v like decode, but leaves the values of
+// the given top level keys as they are.
+func (r renderer) decodeExcept(b []byte, v any, skip map[string]bool) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode {
+		m := doc.Content[0]
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			if skip[m.Content[i].Value] {
+				continue
+			}
+			if err := r.renderNode(m.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		return doc.Decode(v)
+	}
+	return r.decodeNode(&doc, v)
+}
+
+// Decodes an already parsed YAML node into v, rendering it first.
+func (r renderer) decodeNode(n *yaml.Node, v any) error {
+	if err := r.renderNode(n); err != nil {
+		return err
+	}
+	return n.Decode(v)
+}
+
+func (r renderer) renderNode(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode && n.ShortTag() == "!!str" && strings.Contains(n.Value, "{") {
+		value, native, err := r.render(n.Value)
+		if err != nil {
+			return &FileError{Line: n.Line, Column: n.Column, Err: err}
+		}
+		n.Value = value
+		// Values that went through as_bool or as_number are left for YAML to
+		// work out the type of, everything else stays a string.
+		if native {
+			n.Tag = ""
+		} else {
+			n.Tag = "!!str"
+		}
+		n.Style = 0
+		return nil
+	}
+	for _, c := range n.Content {
+		if err := r.renderNode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Renders a template. native is true when the whole template is a single
+// expression that was filtered with as_bool, as_number or as_native.
+func (r renderer) render(tmpl string) (string, bool, error) {
+	var (
+		out    strings.Builder
+		parts  int
+		native bool
+	)
+	for tmpl != "" {
+		start := strings.Index(tmpl, "{")
+		if start < 0 || start == len(tmpl)-1 {
+			out.WriteString(tmpl)
+			parts++
+			break
+		}
+		switch tmpl[start+1] {
+		case '{':
+			end := strings.Index(tmpl[start:], "}}")
+			if end < 0 {
+				return "", false, fmt.Errorf("unclosed expression in %q", tmpl)
+			}
+			if start > 0 {
+				out.WriteString(tmpl[:start])
+				parts++
+			}
+			p := &exprParser{src: tmpl[start+2 : start+end], r: r}
+			v, err := p.parse()
+			if err != nil {
+				return "", false, fmt.Errorf("failed to render %q: %w", tmpl, err)
+			}
+			out.WriteString(v.text())
+			native = v.native
+			parts++
+			tmpl = tmpl[start+end+2:]
+		case '#':
+			end := strings.Index(tmpl[start:], "#}")
+			if end < 0 {
+				return "", false, fmt.Errorf("unclosed comment in %q", tmpl)
+			}
+			if start > 0 {
+				out.WriteString(tmpl[:start])
+				parts++
+			}
+			tmpl = tmpl[start+end+2:]
+		case '%':
+			return "", false, fmt.Errorf("jinja statements are not supported in %q", tmpl)
+		default:
+			out.WriteString(tmpl[:start+1])
+			parts++
+			tmpl = tmpl[start+1:]
+		}
+	}
+	return out.String(), native && parts == 1, nil
+}
+
+// The result of an expression. native is set by the as_bool and as_number
+// filters, which dbt uses to get something other than a string out of a
+// template.
+type value struct {
+	v      any
+	native bool
+}
+
+func (v value) text() string {
+	switch t := v.v.(type) {
+	case nil:
+		return "None"
+	case string:
+		return t
+	case bool:
+		// Jinja renders Python's booleans.
+		if t {
+			return "True"
+		}
+		return "False"
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// A recursive descent parser that evaluates expressions as it goes, they're
+// too simple to be worth building a tree for.
+//
+//	expr    := filtered ("~" filtered)*
+//	filtered := primary ("|" ident)*
+//	primary := string | number | ident | ident "(" args ")" | "(" expr ")"
+type exprParser struct {
+	src string
+	pos int
+	r   renderer
+}
+
+func (p *exprParser) parse() (value, error) {
+	v, err := p.expr()
+	if err != nil {
+		return value{}, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return value{}, fmt.Errorf("unexpected %q", p.src[p.pos:])
+	}
+	return v, nil
+}
+
+func (p *exprParser) expr() (value, error) {
+	v, err := p.filtered()
+	if err != nil {
+		return value{}, err
+	}
+	for p.accept('~') {
+		rhs, err := p.filtered()
+		if err != nil {
+			return value{}, err
+		}
+		v = value{v: v.text() + rhs.text()}
+	}
+	return v, nil
+}
+
+func (p *exprParser) filtered() (value, error) {
+	v, err := p.primary()
+	if err != nil {
+		return value{}, err
+	}
+	for p.accept('|') {
+		name := p.ident()
+		switch name {
+		case "as_bool":
+			b, err := strconv.ParseBool(v.text())
+			if err != nil {
+				return value{}, fmt.Errorf("%q is not a boolean", v.text())
+			}
+			v = value{v: b, native: true}
+		case "as_number":
+			text := strings.TrimSpace(v.text())
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return value{}, fmt.Errorf("%q is not a number", text)
+			}
+			// Keep the text as it was so integers stay integers.
+			v = value{v: text, native: true}
+		case "as_text", "as_native":
+			v.native = name == "as_native"
+		case "":
+			return value{}, fmt.Errorf("expected a filter name")
+		default:
+			return value{}, fmt.Errorf("filter %s is not supported", name)
+		}
+	}
+	return v, nil
+}
+
+func (p *exprParser) primary() (value, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return value{}, fmt.Errorf("unexpected end of expression")
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '\'' || c == '"':
+		s, err := p.string()
+		return value{v: s}, err
+	case c == '-' || unicode.IsDigit(rune(c)):
+		return p.number()
+	case c == '(':
+		p.pos++
+		v, err := p.expr()
+		if err != nil {
+			return value{}, err
+		}
+		if !p.accept(')') {
+			return value{}, fmt.Errorf("expected )")
+		}
+		return v, nil
+	}
+
+	name := p.ident()
+	switch name {
+	case "":
+		return value{}, fmt.Errorf("unexpected %q", p.src[p.pos:])
+	case "true", "True":
+		return value{v: true}, nil
+	case "false", "False":
+		return value{v: false}, nil
+	case "none", "None":
+		return value{}, nil
+	}
+	if !p.accept('(') {
+		return value{}, fmt.Errorf("%s is not defined", name)
+	}
+	args, err := p.args()
+	if err != nil {
+		return value{}, err
+	}
+	return p.call(name, args)
+}
+
+func (p *exprParser) args() ([]value, error) {
+	var args []value
+	if p.accept(')') {
+		return args, nil
+	}
+	for {
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+		if p.accept(')') {
+			return args, nil
+		}
+		if !p.accept(',') {
+			return nil, fmt.Errorf("expected , or )")
+		}
+	}
+}
+
+func (p *exprParser) call(name string, args []value) (value, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return value{}, fmt.Errorf("%s takes a name and an optional default", name)
+	}
+	key := args[0].text()
+
+	switch name {
+	case "env_var":
+		if v, ok := os.LookupEnv(key); ok {
+			return value{v: v}, nil
+		}
+		if len(args) == 2 {
+			return args[1], nil
+		}
+		return value{}, fmt.Errorf("env var required but not provided: %s", key)
+	case "var":
+		if v, ok := p.r.vars[key]; ok {
+			return value{v: v}, nil
+		}
+		if len(args) == 2 {
+			return args[1], nil
+		}
+		return value{}, fmt.Errorf("required var %s not provided", key)
+	default:
+		return value{}, fmt.Errorf("function %s is not supported", name)
+	}
+}
+
+func (p *exprParser) string() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.src):
+			b.WriteByte(p.src[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *exprParser) number() (value, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) && (unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return value{v: n}, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return value{}, fmt.Errorf("invalid number %s", text)
+	}
+	return value{v: f}, nil
+}
+
+func (p *exprParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := rune(p.src[p.pos])
+		if !(c == '_' || unicode.IsLetter(c) || (p.pos > start && unicode.IsDigit(c))) {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *exprParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
@@ -2,64 +2,182 @@ package dbt
 
 import (
 	"encoding/json"
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
 
-	"github.com/mitchellh/mapstructure"
+// The manifest schema versions dal understands. v5 is dbt 1.1, v12 is 1.8.
+const (
+	minManifestVersion = 5
+	maxManifestVersion = 12
 )
 
-func LoadManifestNodes() []Node {
-	// Look for the manifest in the default location
-	f, err := os.Open("./target/manifest.json")
+// The catalog has been at v1 since it was introduced.
+const catalogVersion = 1
+
+var schemaVersionPattern = regexp.MustCompile(`/dbt/(\w+)/v(\d+)\.json$`)
+
+// Works out the version of an artifact from its dbt_schema_version, which is
+// the URL of its JSON schema, e.g.
+// https://schemas.getdbt.com/dbt/manifest/v12.json
+func schemaVersion(kind, url string) (int, error) {
+	m := schemaVersionPattern.FindStringSubmatch(url)
+	if m == nil {
+		return 0, fmt.Errorf("%s has no recognisable dbt_schema_version %q, was it generated by dbt?", kind, url)
+	}
+	if m[1] != kind {
+		return 0, fmt.Errorf("expected a %s but got a %s", kind, m[1])
+	}
+	return strconv.Atoi(m[2])
+}
+
+// Loads the manifest from the given artifacts location, see openArtifact.
+func LoadManifest(location string) (*Manifest, error) {
+	f, err := openArtifact(location, "manifest.json")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer f.Close()
 
-	// Decode the manifest
-	var dbtManifest map[string]any
-	err = json.NewDecoder(f).Decode(&dbtManifest)
+	m, err := ParseManifest(f)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fileError(artifactPath(location, "manifest.json"), err)
 	}
+	return m, nil
+}
+
+// The parts of a manifest dal uses.
+type Manifest struct {
+	Metadata ArtifactMetadata
+	// The models exposed through dal.
+	Nodes []Node
+}
 
-	// Get the nodes off of the manifest, that's all we care about.
-	nodes, ok := dbtManifest["nodes"]
-	if !ok {
-		log.Fatal("invalid dbt manifest")
+// Whether the named model is one of the exposed ones.
+func (m *Manifest) exposes(name string) bool {
+	for _, node := range m.Nodes {
+		if node.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The metadata dbt writes at the top of each artifact. It's the same for the
+// manifest and the catalog.
+type ArtifactMetadata struct {
+	DbtSchemaVersion string    `json:"dbt_schema_version"`
+	DbtVersion       string    `json:"dbt_version"`
+	GeneratedAt      time.Time `json:"generated_at"`
+	InvocationID     string    `json:"invocation_id"`
+}
+
+// Reads the exposed models out of a manifest, smoothing over the differences
+// between the schema versions. Manifests from versions dal doesn't know about
+// are rejected rather than half understood.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var dbtManifest struct {
+		Metadata ArtifactMetadata           `json:"metadata"`
+		Nodes    map[string]json.RawMessage `json:"nodes"`
+	}
+	if err := json.Unmarshal(b, &dbtManifest); err != nil {
+		return nil, jsonError(b, fmt.Errorf("invalid dbt manifest: %w", err))
+	}
+
+	version, err := schemaVersion("manifest", dbtManifest.Metadata.DbtSchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	if version < minManifestVersion || version > maxManifestVersion {
+		return nil, fmt.Errorf(
+			"manifest v%d (dbt %s) is not supported, dal supports v%d to v%d, which is dbt 1.1 to 1.8",
+			version, dbtManifest.Metadata.DbtVersion, minManifestVersion, maxManifestVersion,
+		)
 	}
 
 	// Look through all the nodes, we're only interested in models which have
 	// been configured for dal to expose.
 	var exposed []Node
-	for _, n := range nodes.(map[string]any) {
+	for id, n := range dbtManifest.Nodes {
 		var node Node
-		config := &mapstructure.DecoderConfig{
-			Metadata: nil,
-			Result:   &node,
-			TagName:  "json",
-		}
-		decoder, err := mapstructure.NewDecoder(config)
-		if err != nil {
-			log.Fatal(err)
+		if err := json.Unmarshal(n, &node); err != nil {
+			return nil, nodeError(id, n, err)
 		}
 
-		err = decoder.Decode(n)
-		if err != nil {
-			log.Fatal(err)
+		// dbt 1.3 (v7) renamed the SQL fields, since models can be python.
+		if version < 7 {
+			node.RawCode = node.RawSql
+			node.CompiledCode = node.CompiledSql
+			node.Language = "sql"
 		}
 
 		if node.ResourceType == "model" && node.Config.Meta.Dal.Expose == true {
+			if err := node.Config.Meta.Dal.validate(); err != nil {
+				return nil, &MetaError{Model: node.Name, Path: node.configPath(), Err: err}
+			}
 			exposed = append(exposed, node)
 			continue
 		}
 	}
 
-	return exposed
+	return &Manifest{Metadata: dbtManifest.Metadata, Nodes: versionedNames(exposed)}, nil
+}
+
+// Explains why a node couldn't be decoded. Mistakes in meta.dal are the
+// likeliest reason, which are told apart so they can point at the model.
+func nodeError(id string, raw json.RawMessage, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && strings.HasPrefix(typeErr.Field, "config.meta.dal") {
+		var node struct {
+			Name             string `json:"name"`
+			PatchPath        string `json:"patch_path"`
+			OriginalFilePath string `json:"original_file_path"`
+		}
+		json.Unmarshal(raw, &node)
+		n := Node{Name: node.Name, PatchPath: node.PatchPath, OriginalFilePath: node.OriginalFilePath}
+		return &MetaError{
+			Model: node.Name,
+			Path:  n.configPath(),
+			Err:   fmt.Errorf("%s should be a %s, not a %s", strings.TrimPrefix(typeErr.Field, "config.meta.dal."), typeErr.Type, typeErr.Value),
+		}
+	}
+	return fmt.Errorf("invalid dbt manifest node %s: %w", id, err)
+}
+
+// Model versions (v9 onwards) share a name, which dal needs to be unique. The
+// latest version keeps the bare name, so a query for the model follows it as
+// new versions come out, and the others are suffixed with _v and their
+// version. That's dal's own naming: dbt suffixes every version's relation,
+// the latest included, and the relation dal queries still comes from the
+// catalog.
+func versionedNames(nodes []Node) []Node {
+	for i, node := range nodes {
+		if node.Version == nil {
+			continue
+		}
+		version := fmt.Sprint(node.Version)
+		if version != fmt.Sprint(node.LatestVersion) {
+			nodes[i].Name = node.Name + "_v" + version
+		}
+	}
+	return nodes
 }
 
 type Node struct {
+	// RawSql and CompiledSql are from manifests before v7, RawCode and
+	// CompiledCode are filled in either way.
 	RawSql       string `json:"raw_sql"`
+	RawCode      string `json:"raw_code"`
+	Language     string `json:"language"`
 	Compiled     bool   `json:"compiled"`
 	ResourceType string `json:"resource_type"`
 	DependsOn    struct {
@@ -81,14 +199,15 @@ type Node struct {
 		Name     string `json:"name"`
 		Checksum string `json:"checksum"`
 	} `json:"checksum"`
-	Tags        []any             `json:"tags"`
-	Refs        [][]string        `json:"refs"`
+	Tags []any `json:"tags"`
+	// Lists of names before v9, objects with a name, package and version
+	// after.
+	Refs        []any             `json:"refs"`
 	Sources     []any             `json:"sources"`
 	Description string            `json:"description"`
 	Columns     map[string]Column `json:"columns"`
-	Meta        struct {
-	} `json:"meta"`
-	Docs struct {
+	Meta        map[string]any    `json:"meta"`
+	Docs        struct {
 		Show bool `json:"show"`
 	} `json:"docs"`
 	PatchPath        string `json:"patch_path"`
@@ -100,9 +219,45 @@ type Node struct {
 	} `json:"unrendered_config"`
 	CreatedAt         float64 `json:"created_at"`
 	CompiledSql       string  `json:"compiled_sql"`
+	CompiledCode      string  `json:"compiled_code"`
 	ExtraCtesInjected bool    `json:"extra_ctes_injected"`
 	ExtraCtes         []any   `json:"extra_ctes"`
 	RelationName      string  `json:"relation_name"`
+
+	// Model governance, from v9 onwards. Versions can be strings or numbers.
+	Contract      Contract     `json:"contract"`
+	Constraints   []Constraint `json:"constraints"`
+	Version       any          `json:"version"`
+	LatestVersion any          `json:"latest_version"`
+	Access        string       `json:"access"`
+	Group         string       `json:"group"`
+}
+
+type Contract struct {
+	Enforced bool `json:"enforced"`
+}
+
+type Constraint struct {
+	Type       string   `json:"type"`
+	Name       string   `json:"name"`
+	Expression string   `json:"expression"`
+	Columns    []string `json:"columns"`
+	// Whether dbt warns about constraints the warehouse won't enforce.
+	WarnUnenforced  bool `json:"warn_unenforced"`
+	WarnUnsupported bool `json:"warn_unsupported"`
+}
+
+// The file a model's config most likely came from, which is its properties
+// file if it has one.
+func (n Node) configPath() string {
+	if n.PatchPath != "" {
+		// These are prefixed with the package, e.g. shop://models/schema.yml
+		if _, path, ok := strings.Cut(n.PatchPath, "://"); ok {
+			return path
+		}
+		return n.PatchPath
+	}
+	return n.OriginalFilePath
 }
 
 type NodeConfig struct {
@@ -135,6 +290,19 @@ type DalNodeConfig struct {
 	ForeignKeys []DalFK `json:"foreign_keys"`
 }
 
+// Checks the config makes sense, beyond being the right shape.
+func (c DalNodeConfig) validate() error {
+	if len(c.ForeignKeys) > 0 && c.PrimaryKey == "" {
+		return errors.New("primary_key is required for foreign_keys")
+	}
+	for i, fk := range c.ForeignKeys {
+		if fk.Model == "" || fk.RightOn == "" {
+			return fmt.Errorf("foreign key %d needs a model and right_on", i+1)
+		}
+	}
+	return nil
+}
+
 type DalFK struct {
 	Model   string `json:"model"`
 	LeftOn  string `json:"left_on"`
@@ -142,11 +310,11 @@ type DalFK struct {
 }
 
 type Column struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Meta        struct {
-	} `json:"meta"`
-	DataType any   `json:"data_type"`
-	Quote    any   `json:"quote"`
-	Tags     []any `json:"tags"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Meta        map[string]any `json:"meta"`
+	DataType    any            `json:"data_type"`
+	Quote       any            `json:"quote"`
+	Tags        []any          `json:"tags"`
+	Constraints []Constraint   `json:"constraints"`
 }
@@ -0,0 +1,117 @@
+package dbt_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/internal/dbt"
+)
+
+func manifestOf(version string, nodes string) string {
+	return fmt.Sprintf(`{
+		"metadata": {"dbt_schema_version": "https://schemas.getdbt.com/dbt/manifest/%s.json", "dbt_version": "1.x"},
+		"nodes": {%s}
+	}`, version, nodes)
+}
+
+const exposed = `"config": {"meta": {"dal": {"expose": true}}}`
+
+func TestParseManifest_Versions(t *testing.T) {
+	cases := []struct {
+		name     string
+		manifest string
+		want     dbt.Node
+	}{
+		{
+			name: "v5 raw_sql",
+			manifest: manifestOf("v5", `"model.shop.orders": {
+				"resource_type": "model", "name": "orders", "raw_sql": "select 1", "compiled_sql": "select 1",
+				"refs": [["customers"]], "root_path": "/app", `+exposed+`}`),
+			want: dbt.Node{Name: "orders", RawCode: "select 1", CompiledCode: "select 1", Language: "sql"},
+		},
+		{
+			name: "v7 raw_code",
+			manifest: manifestOf("v7", `"model.shop.orders": {
+				"resource_type": "model", "name": "orders", "raw_code": "select 1", "language": "sql", `+exposed+`}`),
+			want: dbt.Node{Name: "orders", RawCode: "select 1", Language: "sql"},
+		},
+		{
+			name: "v12 governance",
+			manifest: manifestOf("v12", `"model.shop.orders": {
+				"resource_type": "model", "name": "orders", "raw_code": "select 1", "language": "python",
+				"refs": [{"name": "customers", "package": null, "version": null}],
+				"contract": {"enforced": true, "alias_types": true, "checksum": null},
+				"constraints": [{"type": "primary_key", "columns": ["id"], "to": null}],
+				"access": "public", "group": "finance", "meta": {"owner": "x"}, `+exposed+`}`),
+			want: dbt.Node{
+				Name: "orders", RawCode: "select 1", Language: "python",
+				Contract:    dbt.Contract{Enforced: true},
+				Constraints: []dbt.Constraint{{Type: "primary_key", Columns: []string{"id"}}},
+				Access:      "public", Group: "finance",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, err := dbt.ParseManifest(strings.NewReader(c.manifest))
+			require.NoError(t, err)
+			require.Len(t, m.Nodes, 1)
+			n := m.Nodes[0]
+			assert.Equal(t, c.want.Name, n.Name)
+			assert.Equal(t, c.want.RawCode, n.RawCode)
+			assert.Equal(t, c.want.CompiledCode, n.CompiledCode)
+			assert.Equal(t, c.want.Language, n.Language)
+			assert.Equal(t, c.want.Contract, n.Contract)
+			assert.Equal(t, c.want.Constraints, n.Constraints)
+			assert.Equal(t, c.want.Access, n.Access)
+			assert.Equal(t, c.want.Group, n.Group)
+		})
+	}
+}
+
+func TestParseManifest_ModelVersions(t *testing.T) {
+	m, err := dbt.ParseManifest(strings.NewReader(manifestOf("v10", `
+		"model.shop.orders.v1": {"resource_type": "model", "name": "orders", "version": 1, "latest_version": 2, `+exposed+`},
+		"model.shop.orders.v2": {"resource_type": "model", "name": "orders", "version": 2, "latest_version": 2, `+exposed+`}
+	`)))
+	require.NoError(t, err)
+
+	var names []string
+	for _, n := range m.Nodes {
+		names = append(names, n.Name)
+	}
+	assert.ElementsMatch(t, []string{"orders", "orders_v1"}, names)
+}
+
+func TestParseManifest_Unsupported(t *testing.T) {
+	cases := []struct {
+		name     string
+		manifest string
+		err      string
+	}{
+		{"too old", manifestOf("v4", ""), "manifest v4 (dbt 1.x) is not supported, dal supports v5 to v12"},
+		{"too new", manifestOf("v13", ""), "manifest v13 (dbt 1.x) is not supported, dal supports v5 to v12"},
+		{"no metadata", `{"nodes": {}}`, "manifest has no recognisable dbt_schema_version"},
+		{"not a manifest", strings.Replace(manifestOf("v1", ""), "manifest", "catalog", 1), "expected a manifest but got a catalog"},
+		{"not json", `nodes: {}`, "invalid dbt manifest"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := dbt.ParseManifest(strings.NewReader(c.manifest))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.err)
+		})
+	}
+}
+
+func TestParseCatalog_Versions(t *testing.T) {
+	c, err := dbt.ParseCatalog(strings.NewReader(catalog))
+	require.NoError(t, err)
+	assert.Equal(t, "ORDERS", c.Nodes["model.shop.orders"].Metadata.Name)
+
+	_, err = dbt.ParseCatalog(strings.NewReader(`{"metadata": {"dbt_schema_version": "https://schemas.getdbt.com/dbt/catalog/v2.json", "dbt_version": "2.0"}}`))
+	assert.EqualError(t, err, "catalog v2 (dbt 2.0) is not supported, dal supports v1")
+}
@@ -0,0 +1,5 @@
+//go:build !cgo
+
+package dbt_test
+
+const haveDuckDB = false
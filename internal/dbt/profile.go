@@ -7,21 +7,13 @@ type Profile struct {
 	Outputs map[string]Output `json:"outputs"`
 }
 
-// TODO: half of this is snowflake specific but that's fine for now
-type Output struct {
-	Type                   string `json:"type"`
-	Account                string `json:"account"`
-	User                   string `json:"user"`
-	Password               string `json:"password"`
-	Role                   string `json:"role"`
-	Database               string `json:"database"`
-	Warehouse              string `json:"warehouse"`
-	Schema                 string `json:"schema"`
-	Threads                int    `json:"threads"`
-	ClientSessionKeepAlive bool   `json:"client_session_keep_alive"`
-	QueryTag               string `json:"query_tag"`
-	ConnectRetries         int    `json:"connect_retries"`
-	ConnectTimeout         int    `json:"connect_timeout"`
-	RetryOnDatabaseErrors  bool   `json:"retry_on_database_errors"`
-	RetryAll               bool   `json:"retry_all"`
+// A target's settings from profiles.yml. These are adapter specific, so they
+// are kept raw and handed to the warehouse adapter named by the target's type
+// to make sense of.
+type Output map[string]any
+
+// The dbt adapter type of the target, e.g. snowflake.
+func (o Output) Type() string {
+	t, _ := o["type"].(string)
+	return t
 }
@@ -0,0 +1,72 @@
+package dbt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/internal/dbt"
+)
+
+func TestLoadProfile_Jinja(t *testing.T) {
+	t.Setenv("DAL_TEST_PASSWORD", "s3cret: {not yaml}")
+	t.Setenv("DAL_TEST_PORT", "5433")
+	t.Setenv("DAL_TEST_KEEPALIVE", "True")
+
+	cases := []struct {
+		name  string
+		value string
+		want  any
+	}{
+		{"literal", `"plain"`, "plain"},
+		{"env var", `"{{ env_var('DAL_TEST_PASSWORD') }}"`, "s3cret: {not yaml}"},
+		{"double quotes", `'{{ env_var("DAL_TEST_PASSWORD") }}'`, "s3cret: {not yaml}"},
+		{"default", `"{{ env_var('DAL_TEST_UNSET', 'fallback') }}"`, "fallback"},
+		{"surrounding text", `"db_{{ env_var('DAL_TEST_UNSET', 'dev') }}_x"`, "db_dev_x"},
+		{"stays a string", `"{{ env_var('DAL_TEST_PORT') }}"`, "5433"},
+		{"as_number", `"{{ env_var('DAL_TEST_PORT') | as_number }}"`, 5433},
+		{"as_bool", `"{{ env_var('DAL_TEST_KEEPALIVE') | as_bool }}"`, true},
+		{"var", `"{{ var('schema') }}"`, "marts"},
+		{"var default", `"{{ var('missing', 'other') }}"`, "other"},
+		{"concat", `"{{ var('schema') ~ '_' ~ env_var('DAL_TEST_PORT') }}"`, "marts_5433"},
+		{"comment", `"a{# ignored #}b"`, "ab"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := writeProfile(t, "value: "+c.value)
+			profile, err := dbt.LoadProfile(dir, "test", map[string]any{"schema": "marts"})
+			require.NoError(t, err)
+			assert.Equal(t, c.want, profile.Outputs["dev"]["value"])
+		})
+	}
+}
+
+func writeProfile(t *testing.T, setting string) string {
+	dir := t.TempDir()
+	profiles := "test:\n  target: dev\n  outputs:\n    dev:\n      type: postgres\n      " + setting + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "profiles.yml"), []byte(profiles), 0o644))
+	return dir
+}
+
+func TestLoadProject_Hooks(t *testing.T) {
+	t.Setenv("DAL_TEST_PROFILE", "shop")
+	dir := t.TempDir()
+	project := `name: shop
+profile: "{{ env_var('DAL_TEST_PROFILE') }}"
+models:
+  shop:
+    +post-hook: "grant select on {{ this }} to role reporter"
+on-run-end:
+  - "{% for schema in schemas %}grant usage on schema {{ schema }} to role reporter;{% endfor %}"
+vars:
+  start: "{{ run_started_at }}"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dbt_project.yml"), []byte(project), 0o644))
+
+	p, err := dbt.LoadProject(dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "shop", p.Profile)
+	assert.Equal(t, "grant select on {{ this }} to role reporter", p.Models["shop"].(map[string]any)["+post-hook"])
+}
@@ -1,7 +1,7 @@
 package dbt
 
 import (
-	"log"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -9,65 +9,155 @@ import (
 )
 
 type Project struct {
-	Name              string                 `json:"name"`
-	ConfigVersion     int                    `json:"config-version"`
-	Version           string                 `json:"version"`
-	Profile           string                 `json:"profile"`
-	ModelPaths        []string               `json:"model-paths"`
-	SeedPaths         []string               `json:"seed-paths"`
-	TestPaths         []string               `json:"test-paths"`
-	AnalysisPaths     []string               `json:"analysis-paths"`
-	MacroPaths        []string               `json:"macro-paths"`
-	TargetPath        string                 `json:"target-path"`
-	CleanTargets      []string               `json:"clean-targets"`
-	RequireDbtVersion []string               `json:"require-dbt-version"`
-	Models            map[string]ModelConfig `json:"models"`
+	Name          string   `json:"name" yaml:"name"`
+	ConfigVersion int      `json:"config-version" yaml:"config-version"`
+	Version       string   `json:"version" yaml:"version"`
+	Profile       string   `json:"profile" yaml:"profile"`
+	ModelPaths    []string `json:"model-paths" yaml:"model-paths"`
+	SeedPaths     []string `json:"seed-paths" yaml:"seed-paths"`
+	TestPaths     []string `json:"test-paths" yaml:"test-paths"`
+	AnalysisPaths []string `json:"analysis-paths" yaml:"analysis-paths"`
+	MacroPaths    []string `json:"macro-paths" yaml:"macro-paths"`
+	TargetPath    string   `json:"target-path" yaml:"target-path"`
+	CleanTargets  []string `json:"clean-targets" yaml:"clean-targets"`
+	// Either a single version range or a list of them.
+	RequireDbtVersion any `json:"require-dbt-version" yaml:"require-dbt-version"`
+	// Model configs are nested by folder to any depth, so they're left raw.
+	Models map[string]any `json:"models" yaml:"models"`
 }
 
-type ModelConfig struct {
-	Materialized string `json:"materialized"`
-	Staging      struct {
-		Materialized string `json:"materialized"`
-	} `json:"staging"`
+// The directory dbt writes its artifacts to, relative to the project.
+func (p *Project) targetPath() string {
+	if p.TargetPath == "" {
+		return "target"
+	}
+	return p.TargetPath
 }
 
-func LoadProject() *Project {
-	f, err := os.Open("./dbt_project.yml")
-	if err != nil {
-		log.Fatal(err)
+// Where to find the dbt project and which profile and target to use, with
+// the same meaning as the dbt flags of the same names. Anything left empty
+// falls back to the DBT_PROFILES_DIR and DBT_TARGET environment variables and
+// then to dbt's defaults.
+type Options struct {
+	ProjectDir  string
+	ProfilesDir string
+	Profile     string
+	Target      string
+	// Values for var() in dbt_project.yml and profiles.yml, like dbt's --vars.
+	Vars map[string]any
+	// Where to load the manifest and catalog from instead of the project's
+	// target-path. Either a directory or an HTTP URL, see openArtifact. The
+	// project itself is optional when this is set, as long as Profile is.
+	Artifacts string
+	// Look up the types of columns in the warehouse's information_schema
+	// when the catalog is missing, or doesn't have the model or column. The
+	// warehouse client is connected to do so.
+	InferTypes bool
+	// Check the catalog's columns against the warehouse, which is connected
+	// to do so.
+	Verify bool
+	// Fail if the artifacts have drifted, rather than leaving out what
+	// drifted.
+	Strict bool
+}
+
+func (o Options) projectDir() string {
+	if o.ProjectDir == "" {
+		return "."
 	}
-	defer f.Close()
+	return o.ProjectDir
+}
 
-	var pc Project
-	err = yaml.NewDecoder(f).Decode(&pc)
+func (o Options) profilesDir() (string, error) {
+	if o.ProfilesDir != "" {
+		return o.ProfilesDir, nil
+	}
+	if dir := os.Getenv("DBT_PROFILES_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("can't find profiles.yml, pass --profiles-dir: %w", err)
+	}
+	return filepath.Join(home, ".dbt"), nil
+}
+
+func (o Options) target(profile Profile) string {
+	if o.Target != "" {
+		return o.Target
 	}
+	if target := os.Getenv("DBT_TARGET"); target != "" {
+		return target
+	}
+	return profile.Target
+}
 
-	return &pc
+// The keys of dbt_project.yml dbt doesn't render when it loads the project.
+// Configs and hooks are rendered per node at run time, where the likes of
+// {{ this }} are defined, and vars are rendered when they're used.
+var unrenderedProjectKeys = map[string]bool{
+	"models":       true,
+	"seeds":        true,
+	"snapshots":    true,
+	"sources":      true,
+	"tests":        true,
+	"data_tests":   true,
+	"unit_tests":   true,
+	"analyses":     true,
+	"metrics":      true,
+	"exposures":    true,
+	"on-run-start": true,
+	"on-run-end":   true,
+	"vars":         true,
 }
 
-func (p *Project) LoadProfile() Profile {
-	// Find the home directory
-	home, err := os.UserHomeDir()
+// Loads the dbt_project.yml in the given directory. Any env_var() or var()
+// calls in it are rendered, apart from those in configs and hooks.
+func LoadProject(dir string, vars map[string]any) (*Project, error) {
+	path := filepath.Join(dir, "dbt_project.yml")
+	b, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fileError(path, err)
 	}
 
-	// Open the profile file
-	f, err := os.Open(filepath.Join(home, ".dbt", "profiles.yml"))
+	var pc Project
+	err = renderer{vars: vars}.decodeExcept(b, &pc, unrenderedProjectKeys)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fileError(path, err)
+	}
+
+	return &pc, nil
+}
+
+// Loads the named profile from the profiles.yml in the given directory. Any
+// env_var() or var() calls in it are rendered.
+func LoadProfile(dir, name string, vars map[string]any) (Profile, error) {
+	if name == "" {
+		return Profile{}, ErrNoProfile
 	}
-	defer f.Close()
 
-	// Load the profiles
-	var profiles Profiles
-	err = yaml.NewDecoder(f).Decode(&profiles)
+	path := filepath.Join(dir, "profiles.yml")
+	b, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return Profile{}, fileError(path, err)
+	}
+
+	// Like dbt, only the profile being used is rendered, so the others can
+	// use env vars that aren't set.
+	var profiles map[string]yaml.Node
+	if err := yaml.Unmarshal(b, &profiles); err != nil {
+		return Profile{}, fileError(path, err)
 	}
+	// profiles.yml can have global config alongside the profiles.
+	delete(profiles, "config")
 
-	// Return the selected profile
-	return profiles[p.Profile]
+	node, ok := profiles[name]
+	if !ok {
+		return Profile{}, &ProfileNotFoundError{Profile: name, Path: path, Available: keys(profiles)}
+	}
+	var profile Profile
+	if err := (renderer{vars: vars}).decodeNode(&node, &profile); err != nil {
+		return Profile{}, fileError(path, err)
+	}
+	return profile, nil
 }
@@ -0,0 +1,103 @@
+package dbt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/internal/dbt"
+)
+
+// The tests tell which profile and target were picked by the warehouse each
+// one is for. Nothing connects, so the non DuckDB ones don't need to exist.
+func writeProfiles(t *testing.T, dir, profiles string) {
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "profiles.yml"), []byte(profiles), 0o644))
+}
+
+func dialect(t *testing.T, opts dbt.Options) string {
+	_, client, _, err := dbt.Inspect(opts)
+	require.NoError(t, err)
+	return client.Dialect()
+}
+
+func TestOptions_Target(t *testing.T) {
+	opts := writeProject(t, 1, 1)
+	writeProfiles(t, opts.ProfilesDir, `shop:
+  target: dev
+  outputs:
+    dev:
+      type: duckdb
+      path: ':memory:'
+    env:
+      type: postgres
+    flag:
+      type: redshift
+`)
+
+	t.Setenv("DBT_TARGET", "")
+	assert.Equal(t, "duckdb", dialect(t, opts), "the profile's target")
+
+	t.Setenv("DBT_TARGET", "env")
+	assert.Equal(t, "postgres", dialect(t, opts), "DBT_TARGET over the profile's target")
+
+	opts.Target = "flag"
+	assert.Equal(t, "redshift", dialect(t, opts), "--target over DBT_TARGET")
+}
+
+func TestOptions_ProfilesDir(t *testing.T) {
+	opts := writeProject(t, 1, 1)
+	home := t.TempDir()
+	writeProfiles(t, filepath.Join(home, ".dbt"), "shop:\n  target: dev\n  outputs:\n    dev:\n      type: redshift\n")
+	env := t.TempDir()
+	writeProfiles(t, env, "shop:\n  target: dev\n  outputs:\n    dev:\n      type: postgres\n")
+	t.Setenv("HOME", home)
+	t.Setenv("DBT_TARGET", "")
+
+	// The project's own profiles.yml is DuckDB.
+	t.Setenv("DBT_PROFILES_DIR", env)
+	assert.Equal(t, "duckdb", dialect(t, opts), "--profiles-dir over DBT_PROFILES_DIR")
+
+	opts.ProfilesDir = ""
+	assert.Equal(t, "postgres", dialect(t, opts), "DBT_PROFILES_DIR over ~/.dbt")
+
+	t.Setenv("DBT_PROFILES_DIR", "")
+	assert.Equal(t, "redshift", dialect(t, opts), "~/.dbt")
+}
+
+func TestOptions_Profile(t *testing.T) {
+	opts := writeProject(t, 1, 1)
+	writeProfiles(t, opts.ProfilesDir, `shop:
+  target: dev
+  outputs:
+    dev:
+      type: duckdb
+      path: ':memory:'
+other:
+  target: dev
+  outputs:
+    dev:
+      type: postgres
+`)
+	t.Setenv("DBT_TARGET", "")
+
+	assert.Equal(t, "duckdb", dialect(t, opts), "the profile in dbt_project.yml")
+
+	opts.Profile = "other"
+	assert.Equal(t, "postgres", dialect(t, opts), "--profile over dbt_project.yml")
+}
+
+func TestOptions_ProjectDir(t *testing.T) {
+	opts := writeProject(t, 2, 1)
+	t.Setenv("DBT_TARGET", "")
+	// The artifacts are found in the project's target-path, relative to the
+	// project rather than the working directory.
+	require.NoError(t, os.Rename(filepath.Join(opts.ProjectDir, "target"), filepath.Join(opts.ProjectDir, "build")))
+	require.NoError(t, os.WriteFile(filepath.Join(opts.ProjectDir, "dbt_project.yml"), []byte("name: shop\nprofile: shop\ntarget-path: build\n"), 0o644))
+
+	schema, _, _, err := dbt.Inspect(opts)
+	require.NoError(t, err)
+	assert.Len(t, schema, 2)
+}
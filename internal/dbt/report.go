@@ -0,0 +1,106 @@
+package dbt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrDrift = errors.New("dbt artifacts have drifted")
+
+// Why a model or column drifted.
+type DriftReason string
+
+const (
+	// The model or column isn't in the catalog, so it was left out.
+	NotInCatalog DriftReason = "not in the catalog"
+	// The column isn't in the catalog, but its type was found in the
+	// warehouse.
+	Inferred DriftReason = "not in the catalog, type inferred from the warehouse"
+	// The model or column is in the catalog but not the warehouse, so it was
+	// left out.
+	NotInWarehouse DriftReason = "not in the warehouse"
+	// The catalog and the warehouse disagree on the column's type. The
+	// warehouse's is used.
+	TypeMismatch DriftReason = "has a different type in the warehouse"
+)
+
+// A model, or a column of one, whose artifacts don't agree.
+type Drift struct {
+	Model string
+	// Empty when the whole model drifted.
+	Column string
+	Reason DriftReason
+	Detail string
+}
+
+func (d Drift) String() string {
+	name := d.Model
+	if d.Column != "" {
+		name += "." + d.Column
+	}
+	s := name + ": " + string(d.Reason)
+	if d.Detail != "" {
+		s += " (" + d.Detail + ")"
+	}
+	return s
+}
+
+// Describes how well the manifest, the catalog and, if it was checked, the
+// warehouse agree with each other.
+type Report struct {
+	Manifest ArtifactMetadata
+	// Nil when there's no catalog.
+	Catalog *ArtifactMetadata
+	// Whether the columns were checked against the warehouse.
+	Verified bool
+	Drift    []Drift
+}
+
+// The catalog is stale when it came from an earlier dbt invocation than the
+// manifest. dbt docs generate writes both, so they normally match.
+func (r *Report) Stale() bool {
+	if r.Catalog == nil || r.Catalog.InvocationID == r.Manifest.InvocationID {
+		return false
+	}
+	return r.Catalog.GeneratedAt.Before(r.Manifest.GeneratedAt)
+}
+
+// Whether everything agrees.
+func (r *Report) OK() bool {
+	return !r.Stale() && len(r.Drift) == 0
+}
+
+func (r *Report) add(d Drift) {
+	r.Drift = append(r.Drift, d)
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "manifest generated at %s by invocation %s\n", r.Manifest.GeneratedAt.Format(time.RFC3339), r.Manifest.InvocationID)
+	switch {
+	case r.Catalog == nil:
+		b.WriteString("no catalog, column types were inferred from the warehouse\n")
+	default:
+		fmt.Fprintf(&b, "catalog generated at %s by invocation %s\n", r.Catalog.GeneratedAt.Format(time.RFC3339), r.Catalog.InvocationID)
+	}
+	if r.Stale() {
+		fmt.Fprintf(&b, "the catalog is %s older than the manifest, run dbt docs generate to refresh it\n",
+			r.Manifest.GeneratedAt.Sub(r.Catalog.GeneratedAt).Round(time.Second))
+	}
+	if r.Verified {
+		b.WriteString("columns were verified against the warehouse\n")
+	}
+
+	if len(r.Drift) == 0 {
+		b.WriteString("no models have drifted")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d drifted:", len(r.Drift))
+	for _, d := range r.Drift {
+		b.WriteString("\n  ")
+		b.WriteString(d.String())
+	}
+	return b.String()
+}
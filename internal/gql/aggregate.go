@@ -0,0 +1,259 @@
+package gql
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/graphql-go/graphql"
+
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+// An aggregate function that can be asked for per column, as in
+// { orders_aggregate { sum { amount } } }.
+type aggregate struct {
+	name        string
+	description string
+	apply       func(col exp.IdentifierExpression) exp.SQLFunctionExpression
+	// Whether the function makes sense for a column of this type.
+	accepts func(dal.Scalar) bool
+	// The GraphQL type of the result for a column of this type.
+	result func(dal.Scalar) graphql.Output
+}
+
+func numeric(t dal.Scalar) bool {
+	return t == dal.Int || t == dal.Float
+}
+
+func ordered(t dal.Scalar) bool {
+	return numeric(t) || t == dal.DateTime
+}
+
+var aggregates = []aggregate{
+	{
+		name:        "count_distinct",
+		description: "Number of distinct non-null values",
+		apply:       func(col exp.IdentifierExpression) exp.SQLFunctionExpression { return goqu.COUNT(goqu.DISTINCT(col)) },
+		accepts:     func(dal.Scalar) bool { return true },
+		result:      func(dal.Scalar) graphql.Output { return graphql.Int },
+	},
+	{
+		name:        "sum",
+		description: "Sum of the values",
+		apply:       func(col exp.IdentifierExpression) exp.SQLFunctionExpression { return goqu.SUM(col) },
+		accepts:     numeric,
+		// Sums of integers can overflow GraphQL's 32 bit Int.
+		result: func(dal.Scalar) graphql.Output { return graphql.Float },
+	},
+	{
+		name:        "avg",
+		description: "Mean of the values",
+		apply:       func(col exp.IdentifierExpression) exp.SQLFunctionExpression { return goqu.AVG(col) },
+		accepts:     numeric,
+		result:      func(dal.Scalar) graphql.Output { return graphql.Float },
+	},
+	{
+		name:        "min",
+		description: "Smallest value",
+		apply:       func(col exp.IdentifierExpression) exp.SQLFunctionExpression { return goqu.MIN(col) },
+		accepts:     ordered,
+		result:      func(t dal.Scalar) graphql.Output { return mapScalarType(t) },
+	},
+	{
+		name:        "max",
+		description: "Largest value",
+		apply:       func(col exp.IdentifierExpression) exp.SQLFunctionExpression { return goqu.MAX(col) },
+		accepts:     ordered,
+		result:      func(t dal.Scalar) graphql.Output { return mapScalarType(t) },
+	},
+}
+
+// Each aggregate is selected under an alias made of the function and column,
+// so any number of them can come back in one row. Grouped columns use
+// "group" as the function.
+func aggregateKey(fn, column string) string {
+	return fmt.Sprintf("%s__%s", fn, recordKey(column))
+}
+
+// Builds the <model>_aggregate root field. It returns a row per group, or a
+// single row when there's no group_by. The filter argument is shared with
+// the model's list field, GraphQL type names have to be unique.
+func (sb *schemaBuilder) buildAggregate(model *dal.Model, filter *graphql.ArgumentConfig) *graphql.Field {
+	// Resolves a field of one of the nested objects to its aliased value.
+	value := func(key string) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (any, error) {
+			return p.Source.(warehouse.Record)[key], nil
+		}
+	}
+	// The nested objects are all read from the same row.
+	row := func(p graphql.ResolveParams) (any, error) {
+		return p.Source, nil
+	}
+
+	fields := graphql.Fields{
+		"count": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Number of rows",
+			Resolve:     value("count"),
+		},
+	}
+	for _, agg := range aggregates {
+		colFields := graphql.Fields{}
+		for _, col := range model.Columns {
+			if agg.accepts(col.Type) {
+				colFields[col.Name] = &graphql.Field{
+					Type:    agg.result(col.Type),
+					Resolve: value(aggregateKey(agg.name, col.Name)),
+				}
+			}
+		}
+		// GraphQL doesn't allow objects without fields.
+		if len(colFields) == 0 {
+			continue
+		}
+		fields[agg.name] = &graphql.Field{
+			Description: agg.description,
+			Resolve:     row,
+			Type: graphql.NewObject(graphql.ObjectConfig{
+				Name:   fmt.Sprintf("%s_aggregate_%s", model.Name, agg.name),
+				Fields: colFields,
+			}),
+		}
+	}
+
+	groupFields := graphql.Fields{}
+	columnValues := graphql.EnumValueConfigMap{}
+	for _, col := range model.Columns {
+		groupFields[col.Name] = &graphql.Field{
+			Type:        mapScalarType(col.Type),
+			Description: col.Description,
+			Resolve:     value(aggregateKey("group", col.Name)),
+		}
+		columnValues[col.Name] = &graphql.EnumValueConfig{Value: col.Name}
+	}
+	fields["group"] = &graphql.Field{
+		Description: "The values of the group_by columns, the rest are null",
+		Resolve:     row,
+		Type: graphql.NewObject(graphql.ObjectConfig{
+			Name:   fmt.Sprintf("%s_aggregate_group", model.Name),
+			Fields: groupFields,
+		}),
+	}
+
+	return &graphql.Field{
+		Description: fmt.Sprintf("Aggregates over %s", model.Name),
+		Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
+			Name:   fmt.Sprintf("%s_aggregate", model.Name),
+			Fields: fields,
+		})),
+		Resolve: buildAggregateResolver(sb.exec, sb.dialect, model),
+		Args: graphql.FieldConfigArgument{
+			"limit": &graphql.ArgumentConfig{
+				Type:        graphql.Int,
+				Description: "Limit the number of groups, 500 by default",
+			},
+			"offset": &graphql.ArgumentConfig{
+				Type:        graphql.Int,
+				Description: "Offset",
+			},
+			"filter": filter,
+			"group_by": &graphql.ArgumentConfig{
+				Type: graphql.NewList(graphql.NewNonNull(graphql.NewEnum(graphql.EnumConfig{
+					Name:   fmt.Sprintf("%s_column", model.Name),
+					Values: columnValues,
+				}))),
+				Description: "Group by",
+			},
+		},
+	}
+}
+
+func buildAggregateResolver(e *executor, dialect sqlDialect, model *dal.Model) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		var (
+			cols  []any
+			group []any
+			keys  []sortKey
+		)
+		if g, ok := p.Args["group_by"]; ok {
+			for _, name := range g.([]any) {
+				col := goqu.C(dialect.column(model, name.(string)))
+				cols = append(cols, col.As(aggregateKey("group", name.(string))))
+				group = append(group, col)
+				keys = append(keys, sortKey{column: name.(string)})
+			}
+		}
+		// Always counting means there's something to select even when only
+		// the groups were asked for.
+		cols = append(cols, goqu.COUNT(goqu.Star()).As("count"))
+
+		// Only compute the aggregates that were asked for, scanning every
+		// column of a large table isn't free.
+		requested := getSelectedAggregates(p)
+		for _, agg := range aggregates {
+			for _, name := range requested[agg.name] {
+				col := goqu.C(dialect.column(model, name))
+				cols = append(cols, agg.apply(col).As(aggregateKey(agg.name, name)))
+			}
+		}
+
+		q := dialect.From(dialect.table(model)).Prepared(true).Select(cols...)
+		q, err := applyFilter(q, dialect, model, p.Args)
+		if err != nil {
+			return warehouse.Records{}, err
+		}
+		if len(group) > 0 {
+			// Ordering by the groups keeps pages from overlapping, with nulls
+			// last on every warehouse, as they are for connections.
+			q = q.GroupBy(group...).Order(keysetOrder(dialect, model, keys)...)
+		}
+
+		// Like the list field, there are at most 500 groups unless a limit
+		// is given. Without a group_by there's only the one row.
+		limit, limited := p.Args["limit"].(int)
+		if !limited && len(group) > 0 {
+			limit, limited = e.defaultLimit(0), true
+		}
+		if limited {
+			if err := e.checkLimit("limit", limit, 0); err != nil {
+				return warehouse.Records{}, err
+			}
+			q = q.Limit(uint(limit))
+		}
+		if o, ok := p.Args["offset"]; ok {
+			q = q.Offset(uint(o.(int)))
+		}
+
+		sql, args, err := q.ToSQL()
+		if err != nil {
+			log.Printf("%v", err)
+			return warehouse.Records{}, err
+		}
+		return e.run(resolveContext(p), sql, args)
+	}
+}
+
+// Returns the columns asked for under each aggregate function, in the order
+// they were asked for.
+func getSelectedAggregates(p graphql.ResolveParams) map[string][]string {
+	requested := map[string][]string{}
+	seen := map[string]bool{}
+	for _, field := range p.Info.FieldASTs {
+		for _, fn := range childFields(p, field, "") {
+			for _, col := range childFields(p, fn, "") {
+				if col.Name.Value == "__typename" {
+					continue
+				}
+				key := aggregateKey(fn.Name.Value, col.Name.Value)
+				if !seen[key] {
+					seen[key] = true
+					requested[fn.Name.Value] = append(requested[fn.Name.Value], col.Name.Value)
+				}
+			}
+		}
+	}
+	return requested
+}
@@ -0,0 +1,459 @@
+package gql
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "PageInfo",
+	Description: "Where a page is in the connection",
+	Fields: graphql.Fields{
+		"hasNextPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*connection).hasNext, nil
+			},
+		},
+		"hasPreviousPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*connection).hasPrevious()
+			},
+		},
+		"startCursor": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				if c := p.Source.(*connection); len(c.edges) > 0 {
+					return c.edges[0].cursor, nil
+				}
+				return nil, nil
+			},
+		},
+		"endCursor": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				if c := p.Source.(*connection); len(c.edges) > 0 {
+					return c.edges[len(c.edges)-1].cursor, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+// A page of a model's rows, as returned by a <model>_connection field.
+type connection struct {
+	edges   []edge
+	hasNext bool
+	// Looks for rows before the cursor the page starts after. Like count,
+	// it's only run when asked for.
+	hasPrevious func() (any, error)
+	// Counts every row that matches the filter. It's only run when
+	// totalCount is asked for.
+	count func() (any, error)
+}
+
+type edge struct {
+	node   warehouse.Record
+	cursor string
+}
+
+// Builds the <model>_connection root field, which pages through a model's
+// rows with cursors rather than offsets. The filter and sort arguments are
+// shared with the model's list field.
+func (sb *schemaBuilder) buildConnection(model *dal.Model, filter, sort *graphql.ArgumentConfig) *graphql.Field {
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: fmt.Sprintf("%s_edge", model.Name),
+		Fields: graphql.Fields{
+			"node": &graphql.Field{
+				Type: sb.types[model.Name],
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(edge).node, nil
+				},
+			},
+			"cursor": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(edge).cursor, nil
+				},
+			},
+		},
+	})
+
+	return &graphql.Field{
+		Description: model.Description,
+		Type: graphql.NewObject(graphql.ObjectConfig{
+			Name: fmt.Sprintf("%s_connection", model.Name),
+			Fields: graphql.Fields{
+				"edges": &graphql.Field{
+					Type: graphql.NewList(edgeType),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(*connection).edges, nil
+					},
+				},
+				"pageInfo": &graphql.Field{
+					Type: graphql.NewNonNull(pageInfoType),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source, nil
+					},
+				},
+				"totalCount": &graphql.Field{
+					Type:        graphql.Int,
+					Description: "Number of rows that match the filter",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(*connection).count()
+					},
+				},
+			},
+		}),
+		Resolve: buildConnectionResolver(sb.exec, sb.dialect, model),
+		Args: graphql.FieldConfigArgument{
+			"first": &graphql.ArgumentConfig{
+				Type:        graphql.Int,
+				Description: "Number of rows in the page, 500 by default",
+			},
+			"after": &graphql.ArgumentConfig{
+				Type:        graphql.String,
+				Description: "Cursor of the row the page starts after",
+			},
+			"filter": filter,
+			"sort":   sort,
+		},
+	}
+}
+
+func buildConnectionResolver(e *executor, dialect sqlDialect, model *dal.Model) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		ctx := resolveContext(p)
+
+		// The primary key comes last so rows with the same sort values still
+		// have a well defined order, and so a unique cursor.
+		keys := sortKeys(model, p.Args)
+		hasPK := false
+		for _, k := range keys {
+			hasPK = hasPK || k.column == model.PrimaryKey
+		}
+		if !hasPK {
+			keys = append(keys, sortKey{column: model.PrimaryKey})
+		}
+
+		// Everything the cursor is made from has to be selected along with
+		// whatever was asked for.
+		var cols []any
+		selected := map[string]bool{}
+		for _, f := range getConnectionFields(model.PrimaryKey, p) {
+			selected[f] = true
+			cols = append(cols, dialect.column(model, f))
+		}
+		for _, k := range keys {
+			if !selected[k.column] {
+				cols = append(cols, dialect.column(model, k.column))
+			}
+		}
+
+		q := dialect.From(dialect.table(model)).Prepared(true)
+		q, err := applyFilter(q, dialect, model, p.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		conn := &connection{
+			hasPrevious: func() (any, error) { return false, nil },
+			count: func() (any, error) {
+				sql, args, err := q.Select(goqu.COUNT(goqu.Star()).As("count")).ToSQL()
+				if err != nil {
+					return nil, err
+				}
+				rs, err := e.run(ctx, sql, args)
+				if err != nil || len(rs) == 0 {
+					return nil, err
+				}
+				return rs[0]["count"], nil
+			},
+		}
+
+		page := q.Select(cols...).Order(keysetOrder(dialect, model, keys)...)
+		if after, ok := p.Args["after"].(string); ok {
+			values, err := decodeCursor(after, model, keys)
+			if err != nil {
+				return nil, err
+			}
+			after := keyset(dialect, model, keys, values)
+			page = page.Where(after)
+			// A row is before the cursor when it isn't after it. Comparisons
+			// with null come out null rather than false, hence the COALESCE.
+			conn.hasPrevious = func() (any, error) {
+				sql, args, err := q.Select(goqu.L("1")).Where(goqu.L("NOT COALESCE(?, FALSE)", after)).Limit(1).ToSQL()
+				if err != nil {
+					return nil, err
+				}
+				rs, err := e.run(ctx, sql, args)
+				if err != nil {
+					return nil, err
+				}
+				return len(rs) > 0, nil
+			}
+		}
+
+		first, ok := p.Args["first"].(int)
+		if !ok {
+			first = e.defaultLimit(1)
+		}
+		if first < 0 {
+			return nil, fmt.Errorf("first must not be negative")
+		}
+		// One more row than the page tells us whether there's another page.
+		if err := e.checkLimit("first", first, 1); err != nil {
+			return nil, err
+		}
+		page = page.Limit(uint(first + 1))
+
+		sql, args, err := page.ToSQL()
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, err
+		}
+		rs, err := e.run(ctx, sql, args)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rs) > first {
+			conn.hasNext = true
+			rs = rs[:first]
+		}
+		for _, r := range rs {
+			cursor, err := encodeCursor(r, model, keys)
+			if err != nil {
+				return nil, err
+			}
+			conn.edges = append(conn.edges, edge{node: r, cursor: cursor})
+		}
+		return conn, nil
+	}
+}
+
+// Orders the rows with nulls as if they were larger than any value, last
+// going up and first going down. Warehouses don't agree on where nulls go by
+// default, and keyset has to know.
+func keysetOrder(dialect sqlDialect, model *dal.Model, keys []sortKey) []exp.OrderedExpression {
+	oes := orderBy(dialect, model, keys)
+	for i, k := range keys {
+		if k.desc {
+			oes[i] = oes[i].NullsFirst()
+		} else {
+			oes[i] = oes[i].NullsLast()
+		}
+	}
+	return oes
+}
+
+// Matches the rows that come after the given values of the sort keys, which
+// for keys a, b and c is
+//
+//	a > ? OR (a = ? AND b > ?) OR (a = ? AND b = ? AND c > ?)
+//
+// with < instead for descending keys. Unlike OFFSET, the warehouse doesn't
+// have to read past the rows before the page to find it. Nulls are taken into
+// account the way keysetOrder sorts them.
+func keyset(dialect sqlDialect, model *dal.Model, keys []sortKey, values []any) exp.Expression {
+	var or []exp.Expression
+	for i, k := range keys {
+		after := keyAfter(goqu.C(dialect.column(model, k.column)), k, k.column == model.PrimaryKey, values[i])
+		if after == nil {
+			continue
+		}
+		var and []exp.Expression
+		for j, prev := range keys[:i] {
+			c := goqu.C(dialect.column(model, prev.column))
+			if values[j] == nil {
+				and = append(and, c.IsNull())
+			} else {
+				and = append(and, dialect.compare(c, "eq", values[j]))
+			}
+		}
+		or = append(or, goqu.And(append(and, after)...))
+	}
+	// The cursor was at the very end.
+	if len(or) == 0 {
+		return goqu.L("1 = 0")
+	}
+	return goqu.Or(or...)
+}
+
+// Matches the values of one key that come after v, or nil when nothing can.
+// Primary keys can't be null, so there's no need to look for nulls in them.
+func keyAfter(c exp.IdentifierExpression, k sortKey, notNull bool, v any) exp.Expression {
+	switch {
+	case !k.desc && v == nil:
+		// Nulls are last.
+		return nil
+	case !k.desc && notNull:
+		return c.Gt(v)
+	case !k.desc:
+		return goqu.Or(c.Gt(v), c.IsNull())
+	case v == nil:
+		// Nulls are first, so everything else is after them.
+		return c.IsNotNull()
+	default:
+		return c.Lt(v)
+	}
+}
+
+// One of the sort keys in a cursor, and the row's value for it. Values are
+// kept as text along with the column's type, so they go back to the
+// warehouse as what they came out as, rather than whatever JSON makes of
+// them.
+type cursorKey struct {
+	Column string     `json:"c"`
+	Desc   bool       `json:"d,omitempty"`
+	Type   dal.Scalar `json:"t"`
+	// Nil for null.
+	Value *string `json:"v"`
+}
+
+// The type a column's values are read out of cursors as.
+func cursorType(model *dal.Model, column string) dal.Scalar {
+	if col, ok := model.Column(column); ok && col.Type != "" {
+		return col.Type
+	}
+	return dal.String
+}
+
+// A cursor holds the sort keys, in order and with their directions, along
+// with the row's values for them, so one from a differently sorted query can
+// be told apart.
+func encodeCursor(r warehouse.Record, model *dal.Model, keys []sortKey) (string, error) {
+	var cks []cursorKey
+	for _, k := range keys {
+		ck := cursorKey{Column: k.column, Desc: k.desc, Type: cursorType(model, k.column)}
+		if v := r[recordKey(k.column)]; v != nil {
+			var text string
+			switch v := v.(type) {
+			case []byte:
+				// Some drivers, like lib/pq for NUMERIC, return text.
+				text = string(v)
+			case time.Time:
+				text = v.Format(time.RFC3339Nano)
+			default:
+				text = fmt.Sprint(v)
+			}
+			ck.Value = &text
+		}
+		cks = append(cks, ck)
+	}
+	b, err := json.Marshal(cks)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string, model *dal.Model, keys []sortKey) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cks []cursorKey
+	if err := json.Unmarshal(b, &cks); err != nil {
+		return nil, errInvalidCursor
+	}
+	if len(cks) != len(keys) {
+		return nil, fmt.Errorf("%w, it's from a query with a different sort", errInvalidCursor)
+	}
+
+	var out []any
+	for i, k := range keys {
+		ck := cks[i]
+		if ck.Column != k.column || ck.Desc != k.desc || ck.Type != cursorType(model, k.column) {
+			return nil, fmt.Errorf("%w, it's from a query with a different sort", errInvalidCursor)
+		}
+		if ck.Value == nil {
+			out = append(out, nil)
+			continue
+		}
+		v, err := parseCursorValue(ck.Type, *ck.Value)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func parseCursorValue(t dal.Scalar, text string) (any, error) {
+	switch t {
+	case dal.Int:
+		return strconv.ParseInt(text, 10, 64)
+	case dal.Float:
+		return strconv.ParseFloat(text, 64)
+	case dal.Boolean:
+		return strconv.ParseBool(text)
+	case dal.DateTime:
+		return time.Parse(time.RFC3339Nano, text)
+	default:
+		return text, nil
+	}
+}
+
+// Returns the columns asked for under edges { node { ... } }, starting with
+// the primary key. Like getSelectedFields, foreign keys are left to their
+// loaders.
+func getConnectionFields(pk string, p graphql.ResolveParams) []string {
+	collect := []string{pk}
+	seen := map[string]bool{pk: true}
+	for _, conn := range p.Info.FieldASTs {
+		for _, edges := range childFields(p, conn, "edges") {
+			for _, node := range childFields(p, edges, "node") {
+				for _, field := range childFields(p, node, "") {
+					n := field.Name.Value
+					if field.SelectionSet == nil && n != "__typename" && !seen[n] {
+						seen[n] = true
+						collect = append(collect, n)
+					}
+				}
+			}
+		}
+	}
+	return collect
+}
+
+// The fields selected on a field with the given name, or all of them when
+// the name is empty. Fields selected through fragments count too.
+func childFields(p graphql.ResolveParams, field *ast.Field, name string) []*ast.Field {
+	if field.SelectionSet == nil {
+		return nil
+	}
+	var fields []*ast.Field
+	for _, selection := range field.SelectionSet.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if name == "" || s.Name.Value == name {
+				fields = append(fields, s)
+			}
+		case *ast.InlineFragment:
+			fields = append(fields, childFields(p, &ast.Field{SelectionSet: s.SelectionSet}, name)...)
+		case *ast.FragmentSpread:
+			if def, ok := p.Info.Fragments[s.Name.Value].(*ast.FragmentDefinition); ok {
+				fields = append(fields, childFields(p, &ast.Field{SelectionSet: def.SelectionSet}, name)...)
+			}
+		}
+	}
+	return fields
+}
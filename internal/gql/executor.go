@@ -0,0 +1,102 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+// Runs the generated SQL against the warehouse on behalf of the resolvers.
+type executor struct {
+	wc warehouse.Client
+	// Queries running longer than this are cancelled. Zero means a query is
+	// only cancelled along with the request that made it.
+	timeout time.Duration
+	// The most rows a single query may return, which bounds how much memory
+	// a request can use. Zero means no limit.
+	maxRows int
+}
+
+// The context queries for a field should run with. They're tagged with the
+// name of the GraphQL operation, if it has one, so warehouse usage can be
+// attributed to it.
+func resolveContext(p graphql.ResolveParams) context.Context {
+	// graphql-go hands resolvers a nil context unless one was given to it.
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if op, ok := p.Info.Operation.(*ast.OperationDefinition); ok && op.Name != nil {
+		ctx = warehouse.WithQueryTag(ctx, op.Name.Value)
+	}
+	return ctx
+}
+
+// Runs a query and calls fn with each row as it's read from the warehouse.
+// The args are bound to the query's placeholders.
+func (e *executor) each(ctx context.Context, query string, args []any, fn func(warehouse.Record)) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	log.Printf("Running query: %s %v", query, args)
+	rows, err := e.wc.Stream(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+		if e.maxRows > 0 && n > e.maxRows {
+			return fmt.Errorf("query returned more than %d rows", e.maxRows)
+		}
+		fn(rows.Record())
+	}
+	return rows.Err()
+}
+
+// Checks a limit asked for against the most rows a query may return, so a
+// query that would return too many fails before it runs rather than part way
+// through. extra is how many more rows than the limit the query fetches.
+func (e *executor) checkLimit(arg string, limit, extra int) error {
+	if e.maxRows > 0 && limit+extra > e.maxRows {
+		return fmt.Errorf("%s can be at most %d", arg, e.maxRows-extra)
+	}
+	return nil
+}
+
+// The limit used when none is given, 500 rows or as many as the query may
+// return if that's fewer.
+func (e *executor) defaultLimit(extra int) int {
+	if e.maxRows > 0 && e.maxRows-extra < 500 {
+		return e.maxRows - extra
+	}
+	return 500
+}
+
+// Runs a query and collects the results. graphql-go needs the whole list from
+// a resolver, so the root, aggregate and connection fields collect their rows
+// here. Only the loaders use rows as they're streamed, sorting them straight
+// into their batches.
+func (e *executor) run(ctx context.Context, query string, args []any) (warehouse.Records, error) {
+	records := warehouse.Records{}
+	err := e.each(ctx, query, args, func(r warehouse.Record) {
+		records = append(records, r)
+	})
+	if err != nil {
+		return warehouse.Records{}, err
+	}
+	return records, nil
+}
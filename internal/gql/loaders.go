@@ -3,13 +3,80 @@ package gql
 import (
 	"context"
 	"log"
+	"sync"
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/graph-gophers/dataloader"
-	"github.com/supasheet/dal/internal/warehouse"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
 )
 
-func buildOneToManyLoader(w warehouse.Client, table, joinKey string) *dataloader.Loader {
+// The loaders for a single request, each built the first time it's used. A
+// batch runs with the context of the Load that started it, so loaders shared
+// between requests would let one request's cancellation, timeout or query
+// tag leak into another's joins.
+type requestLoaders struct {
+	mu      sync.Mutex
+	loaders map[string]*dataloader.Loader
+}
+
+type loadersKey struct{}
+
+// Returns the request's loader with the given name, building it if it's the
+// first time it's asked for.
+func requestLoader(ctx context.Context, name string, build func() *dataloader.Loader) *dataloader.Loader {
+	rl, ok := ctx.Value(loadersKey{}).(*requestLoaders)
+	if !ok {
+		// Only when the schema is run without loadersExtension, in which case
+		// there's nothing to batch with.
+		return build()
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	l, ok := rl.loaders[name]
+	if !ok {
+		l = build()
+		rl.loaders[name] = l
+	}
+	return l
+}
+
+// A graphql-go extension that gives each request its own loaders, see
+// requestLoaders. Init is the only hook it needs.
+type loadersExtension struct{}
+
+func (loadersExtension) Init(ctx context.Context, _ *graphql.Params) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, loadersKey{}, &requestLoaders{loaders: map[string]*dataloader.Loader{}})
+}
+
+func (loadersExtension) Name() string { return "loaders" }
+
+func (loadersExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (loadersExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (loadersExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (loadersExtension) ResolveFieldDidStart(ctx context.Context, _ *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	return ctx, func(any, error) {}
+}
+
+func (loadersExtension) HasResult() bool { return false }
+
+func (loadersExtension) GetResult(context.Context) any { return nil }
+
+func buildOneToManyLoader(e *executor, dialect sqlDialect, model *dal.Model, joinKey string) *dataloader.Loader {
 	batchFn := func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
 		// First we need to get the list of ids to run the query with.
 		var ids []any
@@ -19,18 +86,17 @@ func buildOneToManyLoader(w warehouse.Client, table, joinKey string) *dataloader
 			ids = append(ids, k.Raw())
 		}
 
-		// Now we can run the query.
-		rs, err := queryByIds(w, table, joinKey, ids)
+		// Now we can run the query, grouping the rows by the join key as they
+		// come in.
+		byKey := make(map[any][]any)
+		err := queryByIds(ctx, e, dialect, model, joinKey, ids, func(r warehouse.Record) {
+			k := r[recordKey(joinKey)]
+			byKey[k] = append(byKey[k], r)
+		})
 		if err != nil {
 			return dataloadErr(err)
 		}
 
-		// Now we can group by the primary keys
-		byKey := make(map[any][]any)
-		for _, r := range rs {
-			byKey[r[joinKey]] = append(byKey[r[joinKey]], r)
-		}
-
 		// Then we can build the dataloader results.
 		var results []*dataloader.Result
 		for _, id := range ids {
@@ -57,20 +123,19 @@ func buildOneToManyLoader(w warehouse.Client, table, joinKey string) *dataloader
 // trivial to get the selected columns into the data loader. It's also very
 // cache friendly. Would have to write a custom non-compliant dataloader to
 // select just the required fields.
-func queryByIds(w warehouse.Client, table, key string, ids []any) (warehouse.Records, error) {
-	q := dialect.From(table).Select(goqu.Star()).Where(goqu.Ex{key: ids})
+func queryByIds(ctx context.Context, e *executor, dialect sqlDialect, model *dal.Model, key string, ids []any, fn func(warehouse.Record)) error {
+	q := dialect.From(dialect.table(model)).Prepared(true).
+		Select(goqu.Star()).Where(goqu.Ex{dialect.column(model, key): ids})
 
-	// Generate the SQL
-	sql, _, err := q.ToSQL()
+	// Generate the SQL, the ids are sent separately as bound parameters.
+	sql, args, err := q.ToSQL()
 	if err != nil {
 		log.Printf("%v", err)
-		return warehouse.Records{}, err
+		return err
 	}
 
 	// Run it
-	cleaned := cleanQuery(sql)
-	log.Printf("Running query: %s", cleaned)
-	return w.Run(cleaned)
+	return e.each(ctx, sql, args, fn)
 }
 
 func dataloadErr(err error) []*dataloader.Result {
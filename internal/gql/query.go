@@ -3,29 +3,59 @@ package gql
 import (
 	"fmt"
 	"log"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/doug-martin/goqu/v9"
-	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/graphql-go/graphql"
 	"github.com/graphql-go/graphql/language/ast"
 	"github.com/mitchellh/mapstructure"
 
-	"github.com/supasheet/dal/internal/dal"
-	"github.com/supasheet/dal/internal/warehouse"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
 )
 
-func init() {
-	opts := goqu.DefaultDialectOptions()
-	// HACK: goqu forces you to quote identifiers but we don't really want
-	// that. So we use the 0 rune so that it is easy to remove later.
-	opts.QuoteRune = 0
-	goqu.RegisterDialect("snowflake", opts)
+// Everything needed to generate SQL for a particular warehouse. goqu quotes
+// every identifier, so they have to be exactly what the warehouse calls them.
+type sqlDialect struct {
+	goqu.DialectWrapper
+	name string
 }
 
-var (
-	dialect = goqu.Dialect("snowflake")
+func newSqlDialect(w warehouse.Client) sqlDialect {
+	return sqlDialect{DialectWrapper: goqu.Dialect(w.Dialect()), name: w.Dialect()}
+}
 
+// The fully qualified identifier for a model's table. Models without a
+// relation fall back to the model name in the default schema.
+func (d sqlDialect) table(model *dal.Model) exp.IdentifierExpression {
+	rel := model.Relation
+	if rel.Identifier == "" {
+		return goqu.T(warehouse.Fold(d.name, model.Name))
+	}
+	// goqu identifiers only go schema.table.column deep, but that renders
+	// database.schema.table just the same. Empty leading parts are left out.
+	return exp.NewIdentifierExpression(rel.Database, rel.Schema, rel.Identifier)
+}
+
+// The identifier for one of a model's columns. That's the name the catalog
+// reported if we have it, otherwise we assume the column was created without
+// quotes and fold it the way the warehouse would have.
+func (d sqlDialect) column(model *dal.Model, name string) string {
+	if col, ok := model.Column(name); ok && col.Identifier != "" {
+		return col.Identifier
+	}
+	return warehouse.Fold(d.name, name)
+}
+
+// Warehouse rows are keyed by lower case column names.
+func recordKey(name string) string {
+	return strings.ToLower(name)
+}
+
+var (
 	dirEnum = graphql.NewEnum(graphql.EnumConfig{
 		Name:        "direction",
 		Description: "Sort direction",
@@ -42,31 +72,148 @@ var (
 	})
 )
 
-func buildFilter(model *dal.Model) *graphql.ArgumentConfig {
-	opFields := graphql.InputObjectConfigFieldMap{}
-	for _, op := range []string{"eq", "neq", "lt", "gt", "lte", "gte"} {
-		opFields[op] = &graphql.InputObjectFieldConfig{
-			// TODO: Look up the type from the manifest and set this appropriately.
-			Type: graphql.String,
+// A 64 bit integer, for filtering integer columns. Values that don't fit in a
+// JSON number can be given as strings.
+var bigIntScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "BigInt",
+	Description: "A 64 bit integer. Values too large for a JSON number can be given as strings.",
+	Serialize:   parseBigInt,
+	ParseValue:  parseBigInt,
+	ParseLiteral: func(v ast.Value) any {
+		switch v := v.(type) {
+		case *ast.IntValue:
+			return parseBigInt(v.Value)
+		case *ast.StringValue:
+			return parseBigInt(v.Value)
+		default:
+			return nil
+		}
+	},
+})
+
+// Returns nil for anything that isn't an integer, which graphql-go reports
+// as an invalid value.
+func parseBigInt(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return int64(v)
+		}
+	case string:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
 		}
 	}
-	iocfm := graphql.InputObjectConfigFieldMap{}
-	for _, col := range model.Columns {
-		iocfm[col.Name] = &graphql.InputObjectFieldConfig{
-			Type: graphql.NewInputObject(
-				graphql.InputObjectConfig{
-					Name:   fmt.Sprintf("filter_%s_%s", model.Name, col.Name),
-					Fields: opFields,
-				},
-			),
+	return nil
+}
+
+// The operators each type of column can be filtered with. Columns of other
+// types are filtered as strings.
+var filterOpsByType = map[dal.Scalar][]string{
+	dal.ID:       {"eq", "neq", "in", "not_in", "is_null"},
+	dal.Int:      {"eq", "neq", "lt", "gt", "lte", "gte", "in", "not_in", "is_null", "between"},
+	dal.Float:    {"eq", "neq", "lt", "gt", "lte", "gte", "in", "not_in", "is_null", "between"},
+	dal.DateTime: {"eq", "neq", "lt", "gt", "lte", "gte", "in", "not_in", "is_null", "between"},
+	dal.Boolean:  {"eq", "neq", "is_null"},
+	dal.String:   {"eq", "neq", "lt", "gt", "lte", "gte", "in", "not_in", "like", "ilike", "is_null", "between"},
+}
+
+var opDescriptions = map[string]string{
+	"eq":      "Equal to",
+	"neq":     "Not equal to",
+	"lt":      "Less than",
+	"gt":      "Greater than",
+	"lte":     "Less than or equal to",
+	"gte":     "Greater than or equal to",
+	"in":      "Equal to one of the values",
+	"not_in":  "Equal to none of the values",
+	"like":    "Matches the pattern, where % matches any characters and _ any one",
+	"ilike":   "Matches the pattern, ignoring case",
+	"is_null": "Is null when true, is not null when false",
+	"between": "Between from and to, inclusive",
+}
+
+// One filter input per type of column, shared by every model, so values are
+// checked against the column's type before they get anywhere near the
+// warehouse. They're named like IntFilter, and the between operator's bounds
+// like IntRange.
+var filterInputs = func() map[dal.Scalar]*graphql.InputObject {
+	inputs := map[dal.Scalar]*graphql.InputObject{}
+	for t, ops := range filterOpsByType {
+		var scalar graphql.Input = mapScalarType(t)
+		// GraphQL's Int is 32 bits, which plenty of ids don't fit in.
+		if t == dal.Int {
+			scalar = bigIntScalar
+		}
+		fields := graphql.InputObjectConfigFieldMap{}
+		for _, op := range ops {
+			var opType graphql.Input = scalar
+			switch op {
+			case "in", "not_in":
+				opType = graphql.NewList(graphql.NewNonNull(scalar))
+			case "is_null":
+				opType = graphql.Boolean
+			case "between":
+				opType = graphql.NewInputObject(graphql.InputObjectConfig{
+					Name: fmt.Sprintf("%sRange", t),
+					Fields: graphql.InputObjectConfigFieldMap{
+						"from": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(scalar)},
+						"to":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(scalar)},
+					},
+				})
+			}
+			fields[op] = &graphql.InputObjectFieldConfig{Type: opType, Description: opDescriptions[op]}
 		}
+		inputs[t] = graphql.NewInputObject(graphql.InputObjectConfig{
+			Name:   fmt.Sprintf("%sFilter", t),
+			Fields: fields,
+		})
 	}
+	return inputs
+}()
+
+func buildFilter(model *dal.Model) *graphql.ArgumentConfig {
+	var input *graphql.InputObject
+	// The fields are a thunk so the filter can refer to itself.
+	fields := func() graphql.InputObjectConfigFieldMap {
+		iocfm := graphql.InputObjectConfigFieldMap{
+			"_and": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewList(graphql.NewNonNull(input)),
+				Description: "All of the filters hold",
+			},
+			"_or": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewList(graphql.NewNonNull(input)),
+				Description: "Any of the filters hold",
+			},
+			"_not": &graphql.InputObjectFieldConfig{
+				Type:        input,
+				Description: "The filter doesn't hold",
+			},
+		}
+		for _, col := range model.Columns {
+			colInput, ok := filterInputs[col.Type]
+			if !ok {
+				colInput = filterInputs[dal.String]
+			}
+			iocfm[col.Name] = &graphql.InputObjectFieldConfig{
+				Type: colInput,
+			}
+		}
+		return iocfm
+	}
+	input = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   fmt.Sprintf("%s_filter", model.Name),
+		Fields: graphql.InputObjectConfigFieldMapThunk(fields),
+	})
 
 	return &graphql.ArgumentConfig{
-		Type: graphql.NewInputObject(graphql.InputObjectConfig{
-			Name:   fmt.Sprintf("%s_filter", model.Name),
-			Fields: iocfm,
-		}),
+		Type:        input,
 		Description: "Filter",
 	}
 }
@@ -88,8 +235,17 @@ func buildSort(model *dal.Model) *graphql.ArgumentConfig {
 	}
 }
 
-func parseFilter(f any) (map[string]map[string]any, error) {
-	var filter map[string]map[string]any
+// A filter argument. The conditions on its columns and its _and, _or and _not
+// filters all have to hold.
+type filter struct {
+	And     []filter                  `json:"_and"`
+	Or      []filter                  `json:"_or"`
+	Not     *filter                   `json:"_not"`
+	Columns map[string]map[string]any `json:",remain"`
+}
+
+func parseFilter(f any) (*filter, error) {
+	var filter filter
 	config := &mapstructure.DecoderConfig{
 		Metadata: nil,
 		Result:   &filter,
@@ -106,51 +262,203 @@ func parseFilter(f any) (map[string]map[string]any, error) {
 		log.Printf("%v", err)
 		return nil, err
 	}
-	return filter, nil
+	return &filter, nil
+}
+
+// A column to sort by.
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// Returns the columns in the sort argument. The argument arrives as a map, so
+// the order it was written in is lost; columns are sorted in the order the
+// model has them instead, which at least makes it the same every time.
+func sortKeys(model *dal.Model, args map[string]any) []sortKey {
+	o, ok := args["sort"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	var keys []sortKey
+	for _, col := range model.Columns {
+		if dir, ok := o[col.Name]; ok {
+			keys = append(keys, sortKey{column: col.Name, desc: dir == "desc"})
+		}
+	}
+	return keys
 }
 
-func buildResolver(w warehouse.Client, model *dal.Model) graphql.FieldResolveFn {
+func orderBy(dialect sqlDialect, model *dal.Model, keys []sortKey) []exp.OrderedExpression {
+	var oes []exp.OrderedExpression
+	for _, k := range keys {
+		c := goqu.C(dialect.column(model, k.column))
+		if k.desc {
+			oes = append(oes, c.Desc())
+		} else {
+			oes = append(oes, c.Asc())
+		}
+	}
+	return oes
+}
+
+// Adds the filter argument, if there is one, to the query's WHERE clause.
+func applyFilter(q *goqu.SelectDataset, dialect sqlDialect, model *dal.Model, args map[string]any) (*goqu.SelectDataset, error) {
+	f, ok := args["filter"]
+	if !ok {
+		return q, nil
+	}
+	// Map the filter
+	filter, err := parseFilter(f)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil, err
+	}
+	return q.Where(dialect.where(model, filter)), nil
+}
+
+// Compiles a filter into an expression for the WHERE clause, nesting the
+// _and, _or and _not filters as deep as they go.
+func (d sqlDialect) where(model *dal.Model, f *filter) exp.ExpressionList {
+	// Columns go in the order the model has them so the SQL is the same
+	// every time.
+	var and []exp.Expression
+	for _, col := range model.Columns {
+		condition, ok := f.Columns[col.Name]
+		if !ok {
+			continue
+		}
+		for _, op := range filterOps {
+			if v, ok := condition[op]; ok {
+				and = append(and, d.compare(goqu.C(d.column(model, col.Name)), op, v))
+			}
+		}
+	}
+	for i := range f.And {
+		and = append(and, d.where(model, &f.And[i]))
+	}
+	if f.Or != nil {
+		var or []exp.Expression
+		for i := range f.Or {
+			// An empty filter holds for every row, so it can't be left out
+			// the way it is from _and.
+			sub := d.where(model, &f.Or[i])
+			if sub.IsEmpty() {
+				or = append(or, goqu.L("1 = 1"))
+			} else {
+				or = append(or, sub)
+			}
+		}
+		// Like IN (), none of nothing holds.
+		if len(or) == 0 {
+			and = append(and, goqu.L("1 = 0"))
+		} else {
+			and = append(and, goqu.Or(or...))
+		}
+	}
+	if f.Not != nil {
+		// Nothing is left once every row is taken out.
+		if not := d.where(model, f.Not); not.IsEmpty() {
+			and = append(and, goqu.L("1 = 0"))
+		} else {
+			and = append(and, goqu.L("NOT ?", not))
+		}
+	}
+	return goqu.And(and...)
+}
+
+// The filter operators, in the order their conditions are added to the
+// WHERE clause.
+var filterOps = []string{"eq", "neq", "lt", "gt", "lte", "gte", "in", "not_in", "like", "ilike", "is_null", "between"}
+
+// The condition for one of the filter operators.
+func (d sqlDialect) compare(col exp.IdentifierExpression, op string, v any) exp.Expression {
+	switch op {
+	// goqu turns comparisons with a bool into IS TRUE, which Snowflake
+	// doesn't have, and IS NOT TRUE matches nulls where != doesn't. Building
+	// the expression directly binds the value instead.
+	case "eq":
+		if _, ok := v.(bool); ok {
+			return exp.NewBooleanExpression(exp.EqOp, col, v)
+		}
+		return col.Eq(v)
+	case "neq":
+		if _, ok := v.(bool); ok {
+			return exp.NewBooleanExpression(exp.NeqOp, col, v)
+		}
+		return col.Neq(v)
+	case "lt":
+		return col.Lt(v)
+	case "gt":
+		return col.Gt(v)
+	case "lte":
+		return col.Lte(v)
+	case "gte":
+		return col.Gte(v)
+	case "in", "not_in":
+		values, _ := v.([]any)
+		// IN () isn't valid SQL. Nothing is in an empty list, and everything
+		// is not in it.
+		if len(values) == 0 {
+			if op == "in" {
+				return goqu.L("1 = 0")
+			}
+			return goqu.L("1 = 1")
+		}
+		if op == "in" {
+			return col.In(values)
+		}
+		return col.NotIn(values)
+	case "like":
+		return col.Like(v)
+	case "ilike":
+		// BigQuery doesn't have ILIKE.
+		if d.name == "bigquery" {
+			return goqu.Func("LOWER", col).Like(goqu.Func("LOWER", v))
+		}
+		return col.ILike(v)
+	case "is_null":
+		if v == true {
+			return col.IsNull()
+		}
+		return col.IsNotNull()
+	case "between":
+		r, _ := v.(map[string]any)
+		return col.Between(exp.NewRangeVal(r["from"], r["to"]))
+	default:
+		panic("unknown filter operator " + op)
+	}
+}
+
+func buildResolver(e *executor, dialect sqlDialect, model *dal.Model) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (any, error) {
 		// Generate the SQL query
-		q := dialect.From(model.Name).Select(getSelectedFields(model.PrimaryKey, p)...)
+		var cols []any
+		for _, f := range getSelectedFields(model.PrimaryKey, p) {
+			cols = append(cols, dialect.column(model, f.(string)))
+		}
+		// Prepared mode means that values are sent separately as bound
+		// parameters rather than being rendered into the SQL.
+		q := dialect.From(dialect.table(model)).Prepared(true).Select(cols...)
 
 		// Handle filter
-		if f, ok := p.Args["filter"]; ok {
-			// Map the filter
-			filter, err := parseFilter(f)
-			if err != nil {
-				log.Printf("%v", err)
-				return warehouse.Records{}, err
-			}
-			// Make a goqu Ex from it
-			wheres := make(goqu.Ex)
-			for field, condition := range filter {
-				wheres[field] = goqu.Op(condition)
-			}
-			q = q.Where(wheres)
+		q, err := applyFilter(q, dialect, model, p.Args)
+		if err != nil {
+			return warehouse.Records{}, err
 		}
 
 		// Handle sort
-		if o, ok := p.Args["sort"]; ok {
-			var oes []exp.OrderedExpression
-			for field, dir := range o.(map[string]any) {
-				c := goqu.C(field)
-				var oe exp.OrderedExpression
-				if dir == "asc" {
-					oe = c.Asc()
-				} else {
-					oe = c.Desc()
-				}
-				oes = append(oes, oe)
-			}
-			q = q.Order(oes...)
+		if keys := sortKeys(model, p.Args); len(keys) > 0 {
+			q = q.Order(orderBy(dialect, model, keys)...)
 		}
 
 		// Handle the limit clause, default to 500
-		limit := 500
+		limit := e.defaultLimit(0)
 		if l, ok := p.Args["limit"]; ok {
 			limit = l.(int)
 		}
+		if err := e.checkLimit("limit", limit, 0); err != nil {
+			return warehouse.Records{}, err
+		}
 		q = q.Limit(uint(limit))
 
 		// Handle the offset
@@ -159,28 +467,15 @@ func buildResolver(w warehouse.Client, model *dal.Model) graphql.FieldResolveFn
 		}
 
 		// Generate the SQL
-		sql, _, err := q.ToSQL()
+		sql, args, err := q.ToSQL()
 		if err != nil {
 			log.Printf("%v", err)
 			return warehouse.Records{}, err
 		}
 
 		// Run it
-		// HACK: goqu forces you to quote identifiers but we don't really want that for snowflake.
-		cleaned := cleanQuery(sql)
-		log.Printf("Running query: %s", cleaned)
-		return w.Run(cleaned)
-	}
-}
-
-func cleanQuery(query string) string {
-	var cleaned []rune
-	for _, r := range query {
-		if r != 0 {
-			cleaned = append(cleaned, r)
-		}
+		return e.run(resolveContext(p), sql, args)
 	}
-	return string(cleaned)
 }
 
 // Returns the list of requested fields from the current part of the query.  It
@@ -1,37 +1,63 @@
 package gql_test
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/graphql-go/graphql"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
-	"github.com/supasheet/dal/internal/dal"
 	"github.com/supasheet/dal/internal/gql"
-	"github.com/supasheet/dal/internal/warehouse"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
 )
 
 type mockClient struct {
 	queries   []string
 	responses []r
+	dialect   string
+	args      [][]any
+	ctxs      []context.Context
 }
 
 type r warehouse.Records
 
 func (mc *mockClient) Connect() error { return nil }
 
-func (mc *mockClient) Run(query string) (warehouse.Records, error) {
+func (mc *mockClient) MapType(string) dal.Scalar { return dal.String }
+
+func (mc *mockClient) Dialect() string {
+	if mc.dialect == "" {
+		return "snowflake"
+	}
+	return mc.dialect
+}
+
+func (mc *mockClient) Run(ctx context.Context, query string, args ...any) (warehouse.Records, error) {
+	rows, err := mc.Stream(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return warehouse.Collect(rows)
+}
+
+func (mc *mockClient) Stream(ctx context.Context, query string, args ...any) (warehouse.Rows, error) {
 	mc.queries = append(mc.queries, query)
+	mc.args = append(mc.args, args)
+	mc.ctxs = append(mc.ctxs, ctx)
 	if mc.responses == nil || len(mc.responses) == 0 {
-		return nil, nil
+		return warehouse.FromRecords(nil), nil
 	}
 	next := mc.responses[0]
 	if len(mc.responses) > 1 {
 		mc.responses = mc.responses[1:]
 	}
-	return warehouse.Records(next), nil
+	return warehouse.FromRecords(warehouse.Records(next)), nil
 }
 
 var schema = dal.Schema{
@@ -56,6 +82,39 @@ var schema = dal.Schema{
 			{Name: "z"},
 		},
 	},
+	// Identifiers as the catalog would report them, including a reserved word
+	// created with quotes and a mixed case name.
+	"baz": &dal.Model{
+		Name:       "baz",
+		PrimaryKey: "id",
+		Relation:   dal.Relation{Database: "ANALYTICS", Schema: "MARTS", Identifier: "BAZ_V2"},
+		Columns: []dal.Column{
+			{Name: "id", Identifier: "ID"},
+			{Name: "order", Identifier: "order"},
+			{Name: "Group", Identifier: "GROUP"},
+		},
+	},
+	// A model with typed columns to aggregate over.
+	"orders": &dal.Model{
+		Name:       "orders",
+		PrimaryKey: "id",
+		Columns: []dal.Column{
+			{Name: "id", Type: dal.Int},
+			{Name: "amount", Type: dal.Float},
+			{Name: "placed_at", Type: dal.DateTime},
+			{Name: "status", Type: dal.String},
+			{Name: "paid", Type: dal.Boolean},
+		},
+	},
+	// An aliased model in a custom schema, without a database.
+	"qux": &dal.Model{
+		Name:       "qux",
+		PrimaryKey: "id",
+		Relation:   dal.Relation{Schema: "marts", Identifier: "qux_v2"},
+		Columns: []dal.Column{
+			{Name: "id"},
+		},
+	},
 }
 
 func TestGenerateSql(t *testing.T) {
@@ -63,7 +122,9 @@ func TestGenerateSql(t *testing.T) {
 		name      string
 		query     string
 		want      []string
+		args      [][]any
 		responses []r
+		dialect   string
 	}
 
 	cases := []tc{
@@ -71,73 +132,351 @@ func TestGenerateSql(t *testing.T) {
 		{
 			name:  "one_field",
 			query: `{foo {a}}`,
-			want:  qs(`SELECT a FROM foo LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" LIMIT ?`),
+			args:  as(a(int64(500))),
 		},
 		{
 			name:  "many_fields",
 			query: `{foo {a b c}}`,
-			want:  qs(`SELECT a, b, c FROM foo LIMIT 500`),
+			want:  qs(`SELECT "A", "B", "C" FROM "FOO" LIMIT ?`),
+			args:  as(a(int64(500))),
+		},
+		{
+			name:    "postgres",
+			query:   `{foo(filter: {a: {eq: "z"}}) {a b}}`,
+			want:    qs(`SELECT "a", "b" FROM "foo" WHERE ("a" = $1) LIMIT $2`),
+			args:    as(a("z", int64(500))),
+			dialect: "postgres",
+		},
+		{
+			name:    "redshift",
+			query:   `{foo(filter: {a: {eq: "z"}}, sort: {b: asc}) {a b}}`,
+			want:    qs(`SELECT "a", "b" FROM "foo" WHERE ("a" = $1) ORDER BY "b" ASC LIMIT $2`),
+			args:    as(a("z", int64(500))),
+			dialect: "redshift",
+		},
+		{
+			name:    "bigquery",
+			query:   `{foo(filter: {a: {eq: "z's"}}) {a b}}`,
+			want:    qs("SELECT `a`, `b` FROM `foo` WHERE (`a` = ?) LIMIT ?"),
+			args:    as(a("z's", int64(500))),
+			dialect: "bigquery",
+		},
+
+		{
+			name:  "identifiers",
+			query: `{baz(filter: {order: {eq: "x"}}, sort: {Group: asc}) {order Group}}`,
+			want:  qs(`SELECT "ID", "order", "GROUP" FROM "ANALYTICS"."MARTS"."BAZ_V2" WHERE ("order" = ?) ORDER BY "GROUP" ASC LIMIT ?`),
+			args:  as(a("x", int64(500))),
+		},
+		{
+			name:    "relation",
+			query:   `{qux {id}}`,
+			want:    qs(`SELECT "id" FROM "marts"."qux_v2" LIMIT $1`),
+			args:    as(a(int64(500))),
+			dialect: "postgres",
 		},
 
 		// Limits and offsets
 		{
 			name:  "limit",
 			query: `{foo(limit: 10) {a b c}}`,
-			want:  qs(`SELECT a, b, c FROM foo LIMIT 10`),
+			want:  qs(`SELECT "A", "B", "C" FROM "FOO" LIMIT ?`),
+			args:  as(a(int64(10))),
 		},
 		{
 			name:  "offset",
 			query: `{foo(offset: 10) {a b c}}`,
-			want:  qs(`SELECT a, b, c FROM foo LIMIT 500 OFFSET 10`),
+			want:  qs(`SELECT "A", "B", "C" FROM "FOO" LIMIT ? OFFSET ?`),
+			args:  as(a(int64(500), int64(10))),
 		},
 		{
 			name:  "limit_offset",
 			query: `{foo(limit: 10, offset: 10) {a b c}}`,
-			want:  qs(`SELECT a, b, c FROM foo LIMIT 10 OFFSET 10`),
+			want:  qs(`SELECT "A", "B", "C" FROM "FOO" LIMIT ? OFFSET ?`),
+			args:  as(a(int64(10), int64(10))),
 		},
 
 		// Filters
 		{
 			name:  "eq",
 			query: `{foo(filter: {a: {eq: "z"}}) {a}}`,
-			want:  qs(`SELECT a FROM foo WHERE (a = 'z') LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" = ?) LIMIT ?`),
+			args:  as(a("z", int64(500))),
 		},
 		{
 			name:  "neq",
 			query: `{foo(filter: {a: {neq: "z"}}) {a}}`,
-			want:  qs(`SELECT a FROM foo WHERE (a != 'z') LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" != ?) LIMIT ?`),
+			args:  as(a("z", int64(500))),
 		},
 		{
 			name:  "lt",
 			query: `{foo(filter: {a: {lt: "z"}}) {a}}`,
-			want:  qs(`SELECT a FROM foo WHERE (a < 'z') LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" < ?) LIMIT ?`),
+			args:  as(a("z", int64(500))),
 		},
 		{
 			name:  "lte",
 			query: `{foo(filter: {a: {lte: "z"}}) {a}}`,
-			want:  qs(`SELECT a FROM foo WHERE (a <= 'z') LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" <= ?) LIMIT ?`),
+			args:  as(a("z", int64(500))),
 		},
 		{
 			name:  "gt",
 			query: `{foo(filter: {a: {gt: "z"}}) {a}}`,
-			want:  qs(`SELECT a FROM foo WHERE (a > 'z') LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" > ?) LIMIT ?`),
+			args:  as(a("z", int64(500))),
 		},
 		{
 			name:  "gte",
 			query: `{foo(filter: {a: {gte: "z"}}) {a}}`,
-			want:  qs(`SELECT a FROM foo WHERE (a >= 'z') LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" >= ?) LIMIT ?`),
+			args:  as(a("z", int64(500))),
+		},
+		{
+			name:  "in",
+			query: `{foo(filter: {a: {in: ["x", "y"]}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" IN (?, ?)) LIMIT ?`),
+			args:  as(a("x", "y", int64(500))),
+		},
+		{
+			name:  "not_in",
+			query: `{foo(filter: {a: {not_in: ["x", "y"]}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" NOT IN (?, ?)) LIMIT ?`),
+			args:  as(a("x", "y", int64(500))),
+		},
+		{
+			name:  "in_empty",
+			query: `{foo(filter: {a: {in: []}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE 1 = 0 LIMIT ?`),
+			args:  as(a(int64(500))),
+		},
+		{
+			name:  "like",
+			query: `{foo(filter: {a: {like: "z%"}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" LIKE ?) LIMIT ?`),
+			args:  as(a("z%", int64(500))),
+		},
+		{
+			name:  "ilike",
+			query: `{foo(filter: {a: {ilike: "z%"}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" ILIKE ?) LIMIT ?`),
+			args:  as(a("z%", int64(500))),
+		},
+		{
+			name:    "ilike_bigquery",
+			query:   `{foo(filter: {a: {ilike: "z%"}}) {a}}`,
+			want:    qs("SELECT `a` FROM `foo` WHERE (LOWER(`a`) LIKE LOWER(?)) LIMIT ?"),
+			args:    as(a("z%", int64(500))),
+			dialect: "bigquery",
+		},
+		{
+			name:  "is_null",
+			query: `{foo(filter: {a: {is_null: true}, b: {is_null: false}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE (("A" IS NULL) AND ("B" IS NOT NULL)) LIMIT ?`),
+			args:  as(a(int64(500))),
+		},
+		{
+			name:  "between",
+			query: `{foo(filter: {a: {between: {from: "a", to: "m"}}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" BETWEEN ? AND ?) LIMIT ?`),
+			args:  as(a("a", "m", int64(500))),
+		},
+		{
+			name:  "many_operators",
+			query: `{foo(filter: {a: {gte: "a", lt: "m", not_in: ["b"]}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE (("A" < ?) AND ("A" >= ?) AND ("A" NOT IN (?))) LIMIT ?`),
+			args:  as(a("m", "a", "b", int64(500))),
+		},
+		{
+			name: "typed",
+			query: `{orders(filter: {id: {in: [1, 2]}, amount: {between: {from: 1, to: 2.5}}, ` +
+				`placed_at: {gte: "2024-03-01T00:00:00Z"}, paid: {eq: true}}) {id}}`,
+			want: qs(`SELECT "ID" FROM "ORDERS" WHERE (("ID" IN (?, ?)) AND ("AMOUNT" BETWEEN ? AND ?) AND ` +
+				`("PLACED_AT" >= ?) AND ("PAID" = ?)) LIMIT ?`),
+			args: as(a(int64(1), int64(2), float64(1), 2.5, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true, int64(500))),
+		},
+		{
+			name:  "or",
+			query: `{foo(filter: {_or: [{a: {eq: "EU"}}, {a: {eq: "UK"}}]}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE (("A" = ?) OR ("A" = ?)) LIMIT ?`),
+			args:  as(a("EU", "UK", int64(500))),
+		},
+		{
+			name:  "or_and_not",
+			query: `{orders(filter: {_or: [{status: {eq: "EU"}}, {status: {eq: "UK"}}], _not: {paid: {eq: true}}, amount: {gt: 1}}) {id}}`,
+			want:  qs(`SELECT "ID" FROM "ORDERS" WHERE (("AMOUNT" > ?) AND (("STATUS" = ?) OR ("STATUS" = ?)) AND NOT ("PAID" = ?)) LIMIT ?`),
+			args:  as(a(float64(1), "EU", "UK", true, int64(500))),
+		},
+		{
+			name:  "nested",
+			query: `{foo(filter: {_and: [{_or: [{a: {eq: "x"}}, {_and: [{b: {eq: "y"}}, {c: {is_null: true}}]}]}], _not: {_or: [{a: {eq: "z"}}, {b: {eq: "z"}}]}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ((("A" = ?) OR (("B" = ?) AND ("C" IS NULL))) AND NOT (("A" = ?) OR ("B" = ?))) LIMIT ?`),
+			args:  as(a("x", "y", "z", "z", int64(500))),
+		},
+		{
+			name:  "or_empty",
+			query: `{foo(filter: {_or: []}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE 1 = 0 LIMIT ?`),
+			args:  as(a(int64(500))),
+		},
+		{
+			name:  "or_empty_filter",
+			query: `{foo(filter: {_or: [{}, {a: {eq: "x"}}]}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE (1 = 1 OR ("A" = ?)) LIMIT ?`),
+			args:  as(a("x", int64(500))),
+		},
+		{
+			name:  "not_empty_filter",
+			query: `{foo(filter: {_not: {}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE 1 = 0 LIMIT ?`),
+			args:  as(a(int64(500))),
+		},
+		{
+			// Snowflake doesn't have IS TRUE, and != shouldn't match nulls.
+			name:  "boolean",
+			query: `{orders(filter: {paid: {neq: false}}) {id}}`,
+			want:  qs(`SELECT "ID" FROM "ORDERS" WHERE ("PAID" != ?) LIMIT ?`),
+			args:  as(a(false, int64(500))),
+		},
+		{
+			// Ids beyond GraphQL's 32 bit Int, as a literal and as a string
+			// for those beyond a JSON number.
+			name:  "big_int",
+			query: `{orders(filter: {id: {gt: 3000000000, lt: "9223372036854775807"}}) {id}}`,
+			want:  qs(`SELECT "ID" FROM "ORDERS" WHERE (("ID" < ?) AND ("ID" > ?)) LIMIT ?`),
+			args:  as(a(int64(9223372036854775807), int64(3000000000), int64(500))),
+		},
+		{
+			name:  "injection",
+			query: `{foo(filter: {a: {eq: "z' OR 1=1 --"}}) {a}}`,
+			want:  qs(`SELECT "A" FROM "FOO" WHERE ("A" = ?) LIMIT ?`),
+			args:  as(a("z' OR 1=1 --", int64(500))),
 		},
 
 		// Sort
 		{
 			name:  "asc",
 			query: `{foo(sort: {a: asc}) {a}}`,
-			want:  qs(`SELECT a FROM foo ORDER BY a ASC LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" ORDER BY "A" ASC LIMIT ?`),
+			args:  as(a(int64(500))),
 		},
 		{
 			name:  "desc",
 			query: `{foo(sort: {a: desc}) {a}}`,
-			want:  qs(`SELECT a FROM foo ORDER BY a DESC LIMIT 500`),
+			want:  qs(`SELECT "A" FROM "FOO" ORDER BY "A" DESC LIMIT ?`),
+			args:  as(a(int64(500))),
+		},
+
+		// Aggregates
+		{
+			name:  "count",
+			query: `{orders_aggregate {count}}`,
+			want:  qs(`SELECT COUNT(*) AS "count" FROM "ORDERS"`),
+			args:  as([]any{}),
+		},
+		{
+			name:  "aggregates",
+			query: `{orders_aggregate {sum {amount} avg {amount id} min {placed_at} max {placed_at amount} count_distinct {status}}}`,
+			want: qs(`SELECT COUNT(*) AS "count", COUNT(DISTINCT("STATUS")) AS "count_distinct__status", SUM("AMOUNT") AS "sum__amount", ` +
+				`AVG("AMOUNT") AS "avg__amount", AVG("ID") AS "avg__id", MIN("PLACED_AT") AS "min__placed_at", ` +
+				`MAX("PLACED_AT") AS "max__placed_at", MAX("AMOUNT") AS "max__amount" FROM "ORDERS"`),
+			args: as([]any{}),
+		},
+		{
+			name:  "aggregate_group_by",
+			query: `{orders_aggregate(filter: {amount: {gt: 10.5}}, group_by: [status]) {group {status} count sum {amount}}}`,
+			want: qs(`SELECT "STATUS" AS "group__status", COUNT(*) AS "count", SUM("AMOUNT") AS "sum__amount" FROM "ORDERS" ` +
+				`WHERE ("AMOUNT" > ?) GROUP BY "STATUS" ORDER BY "STATUS" ASC NULLS LAST LIMIT ?`),
+			args: as(a(10.5, int64(500))),
+		},
+		{
+			name: "aggregate_fragments",
+			query: `{orders_aggregate {...totals ... on orders_aggregate {max {amount}} sum {... on orders_aggregate_sum {amount}}}} ` +
+				`fragment totals on orders_aggregate {avg {amount}}`,
+			want: qs(`SELECT COUNT(*) AS "count", SUM("AMOUNT") AS "sum__amount", AVG("AMOUNT") AS "avg__amount", MAX("AMOUNT") AS "max__amount" FROM "ORDERS"`),
+			args: as([]any{}),
+		},
+		{
+			name:  "aggregate_limit_offset",
+			query: `{orders_aggregate(group_by: [status], limit: 10, offset: 20) {count}}`,
+			want:  qs(`SELECT "STATUS" AS "group__status", COUNT(*) AS "count" FROM "ORDERS" GROUP BY "STATUS" ORDER BY "STATUS" ASC NULLS LAST LIMIT ? OFFSET ?`),
+			args:  as(a(int64(10), int64(20))),
+		},
+		{
+			name:  "aggregate_postgres",
+			query: `{baz_aggregate(group_by: [order, Group]) {count}}`,
+			want: qs(`SELECT "order" AS "group__order", "GROUP" AS "group__group", COUNT(*) AS "count" FROM "ANALYTICS"."MARTS"."BAZ_V2" GROUP BY "order", "GROUP" ` +
+				`ORDER BY "order" ASC NULLS LAST, "GROUP" ASC NULLS LAST LIMIT $1`),
+			args:    as(a(int64(500))),
+			dialect: "postgres",
+		},
+
+		// Connections
+		{
+			name:  "connection",
+			query: `{foo_connection(first: 2) {edges {node {b}}}}`,
+			want:  qs(`SELECT "A", "B" FROM "FOO" ORDER BY "A" ASC NULLS LAST LIMIT ?`),
+			args:  as(a(int64(3))),
+		},
+		{
+			name:  "connection_fragment",
+			query: `{foo_connection(first: 2) {edges {node {...fields}}}} fragment fields on foo {b}`,
+			want:  qs(`SELECT "A", "B" FROM "FOO" ORDER BY "A" ASC NULLS LAST LIMIT ?`),
+			args:  as(a(int64(3))),
+		},
+		{
+			name:  "connection_after",
+			query: fmt.Sprintf(`{foo_connection(sort: {c: desc}, after: %q) {edges {cursor}}}`, cursor(`[{"c":"c","d":true,"t":"String","v":"x"},{"c":"a","t":"String","v":"7"}]`)),
+			want: qs(`SELECT "A", "C" FROM "FOO" WHERE (("C" < ?) OR (("C" = ?) AND ("A" > ?))) ` +
+				`ORDER BY "C" DESC NULLS FIRST, "A" ASC NULLS LAST LIMIT ?`),
+			args: as(a("x", "x", "7", int64(501))),
+		},
+		{
+			name:  "connection_after_asc",
+			query: fmt.Sprintf(`{foo_connection(sort: {c: asc}, after: %q) {edges {cursor}}}`, cursor(`[{"c":"c","t":"String","v":"x"},{"c":"a","t":"String","v":"7"}]`)),
+			want: qs(`SELECT "A", "C" FROM "FOO" WHERE ((("C" > ?) OR ("C" IS NULL)) OR (("C" = ?) AND ("A" > ?))) ` +
+				`ORDER BY "C" ASC NULLS LAST, "A" ASC NULLS LAST LIMIT ?`),
+			args: as(a("x", "x", "7", int64(501))),
+		},
+		{
+			// Nulls come first going down, so everything else is after them.
+			name:  "connection_after_null_desc",
+			query: fmt.Sprintf(`{foo_connection(sort: {c: desc}, after: %q) {edges {cursor}}}`, cursor(`[{"c":"c","d":true,"t":"String","v":null},{"c":"a","t":"String","v":"7"}]`)),
+			want: qs(`SELECT "A", "C" FROM "FOO" WHERE (("C" IS NOT NULL) OR (("C" IS NULL) AND ("A" > ?))) ` +
+				`ORDER BY "C" DESC NULLS FIRST, "A" ASC NULLS LAST LIMIT ?`),
+			args: as(a("7", int64(501))),
+		},
+		{
+			// Nulls come last going up, so only the rest of the nulls are after
+			// them.
+			name:  "connection_after_null_asc",
+			query: fmt.Sprintf(`{foo_connection(sort: {c: asc}, after: %q) {edges {cursor}}}`, cursor(`[{"c":"c","t":"String","v":null},{"c":"a","t":"String","v":"7"}]`)),
+			want: qs(`SELECT "A", "C" FROM "FOO" WHERE (("C" IS NULL) AND ("A" > ?)) ` +
+				`ORDER BY "C" ASC NULLS LAST, "A" ASC NULLS LAST LIMIT ?`),
+			args: as(a("7", int64(501))),
+		},
+		{
+			name: "connection_after_datetime",
+			query: fmt.Sprintf(`{orders_connection(sort: {placed_at: asc}, after: %q) {edges {cursor}}}`,
+				cursor(`[{"c":"placed_at","t":"DateTime","v":"2024-03-01T12:00:00.5Z"},{"c":"id","t":"Int","v":"7"}]`)),
+			want: qs(`SELECT "ID", "PLACED_AT" FROM "ORDERS" WHERE ((("PLACED_AT" > ?) OR ("PLACED_AT" IS NULL)) OR (("PLACED_AT" = ?) AND ("ID" > ?))) ` +
+				`ORDER BY "PLACED_AT" ASC NULLS LAST, "ID" ASC NULLS LAST LIMIT ?`),
+			args: as(a(
+				time.Date(2024, 3, 1, 12, 0, 0, 5e8, time.UTC),
+				time.Date(2024, 3, 1, 12, 0, 0, 5e8, time.UTC),
+				int64(7), int64(501),
+			)),
+		},
+		{
+			name:  "connection_total_count",
+			query: `{foo_connection(filter: {b: {eq: "z"}}) {totalCount}}`,
+			want: qs(
+				`SELECT "A" FROM "FOO" WHERE ("B" = ?) ORDER BY "A" ASC NULLS LAST LIMIT ?`,
+				`SELECT COUNT(*) AS "count" FROM "FOO" WHERE ("B" = ?)`,
+			),
+			args: as(a("z", int64(501)), a("z")),
 		},
 
 		// Join
@@ -145,8 +484,12 @@ func TestGenerateSql(t *testing.T) {
 			name:  "join",
 			query: `{ bar { x foo { b c } } }`,
 			want: qs(
-				`SELECT x FROM bar LIMIT 500`,
-				`SELECT * FROM foo WHERE (a IN (1, 2))`,
+				`SELECT "X" FROM "BAR" LIMIT ?`,
+				`SELECT * FROM "FOO" WHERE ("A" IN (?, ?))`,
+			),
+			args: as(
+				a(int64(500)),
+				a(int64(1), int64(2)),
 			),
 			responses: []r{
 				r{
@@ -166,8 +509,8 @@ func TestGenerateSql(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			// Build the GraphQL schema
-			mc := &mockClient{responses: c.responses}
-			schema, _ := gql.BuildSchema(mc, schema)
+			mc := &mockClient{responses: c.responses, dialect: c.dialect}
+			schema, _ := gql.BuildSchema(mc, schema, gql.Config{})
 
 			// Run the query
 			result := graphql.Do(graphql.Params{
@@ -175,8 +518,8 @@ func TestGenerateSql(t *testing.T) {
 				RequestString: c.query,
 			})
 
-			// Inspect the captured SQL
-			if !assert.Equal(t, c.want, mc.queries) {
+			// Inspect the captured SQL and its bound parameters
+			if !assert.Equal(t, c.want, mc.queries) || !assert.Equal(t, c.args, mc.args) {
 				// Helpful to print out the result when the test fails.
 				b, _ := json.MarshalIndent(result, "", "  ")
 				fmt.Println("Result:")
@@ -186,6 +529,334 @@ func TestGenerateSql(t *testing.T) {
 	}
 }
 
+type ctxKey struct{}
+
+func TestQueryContext(t *testing.T) {
+	mc := &mockClient{
+		responses: []r{
+			r{{"x": 1}},
+			r{{"a": 1, "b": 3, "c": 7}},
+		},
+	}
+	schema, err := gql.BuildSchema(mc, schema, gql.Config{QueryTimeout: time.Minute})
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	graphql.Do(graphql.Params{
+		Schema:        *schema,
+		RequestString: `{ bar { x foo { b } } }`,
+		Context:       ctx,
+	})
+
+	// Both the root query and the batched join should carry the request
+	// context, with the query timeout applied.
+	require.Len(t, mc.ctxs, 2)
+	for _, qctx := range mc.ctxs {
+		assert.Equal(t, "request", qctx.Value(ctxKey{}))
+		_, ok := qctx.Deadline()
+		assert.True(t, ok)
+	}
+}
+
+// Joins are batched within a request, but never across requests, so each
+// request's join runs with its own context.
+func TestQueryContext_Loaders(t *testing.T) {
+	mc := &mockClient{
+		responses: []r{
+			r{{"x": 1}},
+			r{{"a": 1, "b": 3, "c": 7}},
+			r{{"x": 1}},
+			r{{"a": 1, "b": 3, "c": 7}},
+		},
+	}
+	schema, err := gql.BuildSchema(mc, schema, gql.Config{})
+	require.NoError(t, err)
+
+	for _, request := range []string{"first", "second"} {
+		graphql.Do(graphql.Params{
+			Schema:        *schema,
+			RequestString: `{ bar { x foo { b } } }`,
+			Context:       context.WithValue(context.Background(), ctxKey{}, request),
+		})
+	}
+
+	require.Len(t, mc.ctxs, 4)
+	for i, want := range []string{"first", "first", "second", "second"} {
+		assert.Equal(t, want, mc.ctxs[i].Value(ctxKey{}))
+	}
+}
+
+func TestQueryTag(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "named", query: `query Dashboard { bar { x foo { b } } }`, want: "Dashboard"},
+		{name: "anonymous", query: `{ bar { x foo { b } } }`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := &mockClient{
+				responses: []r{
+					r{{"x": 1}},
+					r{{"a": 1, "b": 3, "c": 7}},
+				},
+			}
+			schema, err := gql.BuildSchema(mc, schema, gql.Config{})
+			require.NoError(t, err)
+
+			graphql.Do(graphql.Params{Schema: *schema, RequestString: tt.query})
+
+			require.Len(t, mc.ctxs, 2)
+			for _, qctx := range mc.ctxs {
+				tag, _ := warehouse.QueryTag(qctx)
+				assert.Equal(t, tt.want, tag)
+			}
+		})
+	}
+}
+
+func TestMixedCaseColumns(t *testing.T) {
+	mc := &mockClient{responses: []r{{{"id": 1, "order": "a", "group": "b"}}}}
+	schema, err := gql.BuildSchema(mc, schema, gql.Config{})
+	require.NoError(t, err)
+
+	result := graphql.Do(graphql.Params{
+		Schema:        *schema,
+		RequestString: `{ baz { order Group } }`,
+	})
+	require.Empty(t, result.Errors)
+	assert.Equal(t,
+		map[string]any{"baz": []any{map[string]any{"order": "a", "Group": "b"}}},
+		result.Data,
+	)
+}
+
+func TestAggregate(t *testing.T) {
+	mc := &mockClient{responses: []r{{
+		{"group__status": "open", "count": 2, "sum__amount": 30.5},
+		{"group__status": "shipped", "count": 1, "sum__amount": 12.0},
+	}}}
+	schema, err := gql.BuildSchema(mc, schema, gql.Config{})
+	require.NoError(t, err)
+
+	result := graphql.Do(graphql.Params{
+		Schema:        *schema,
+		RequestString: `{ orders_aggregate(group_by: [status]) { group { status } count sum { amount } } }`,
+	})
+	require.Empty(t, result.Errors)
+	assert.Equal(t,
+		map[string]any{"orders_aggregate": []any{
+			map[string]any{"group": map[string]any{"status": "open"}, "count": 2, "sum": map[string]any{"amount": 30.5}},
+			map[string]any{"group": map[string]any{"status": "shipped"}, "count": 1, "sum": map[string]any{"amount": 12.0}},
+		}},
+		result.Data,
+	)
+
+	// Only numeric columns can be summed.
+	result = graphql.Do(graphql.Params{
+		Schema:        *schema,
+		RequestString: `{ orders_aggregate { sum { status } } }`,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, `Cannot query field "status"`)
+}
+
+func TestTypedFilters(t *testing.T) {
+	mc := &mockClient{}
+	schema, err := gql.BuildSchema(mc, schema, gql.Config{})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{`{ orders(filter: {id: {eq: "1x"}}) { id } }`, `Expected type "BigInt", found "1x"`},
+		{`{ orders(filter: {id: {eq: 1.5}}) { id } }`, `Expected type "BigInt", found 1.5`},
+		{`{ orders(filter: {paid: {lt: true}}) { id } }`, `In field "lt": Unknown field`},
+		{`{ orders(filter: {amount: {like: "1%"}}) { id } }`, `In field "like": Unknown field`},
+		{`{ orders(filter: {placed_at: {gt: "yesterday"}}) { id } }`, `Expected type "DateTime"`},
+	} {
+		result := graphql.Do(graphql.Params{Schema: *schema, RequestString: tt.query})
+		require.Len(t, result.Errors, 1, tt.query)
+		assert.Contains(t, result.Errors[0].Message, tt.want)
+	}
+	assert.Empty(t, mc.queries)
+}
+
+func TestConnection(t *testing.T) {
+	mc := &mockClient{responses: []r{{{"a": 1, "b": "x"}, {"a": 2, "b": "y"}, {"a": 3, "b": "z"}}}}
+	schema, err := gql.BuildSchema(mc, schema, gql.Config{})
+	require.NoError(t, err)
+
+	result := graphql.Do(graphql.Params{
+		Schema:        *schema,
+		RequestString: `{ foo_connection(first: 2) { edges { node { b } } pageInfo { hasNextPage endCursor } } }`,
+	})
+	require.Empty(t, result.Errors)
+	assert.Equal(t,
+		map[string]any{"foo_connection": map[string]any{
+			"edges": []any{
+				map[string]any{"node": map[string]any{"b": "x"}},
+				map[string]any{"node": map[string]any{"b": "y"}},
+			},
+			"pageInfo": map[string]any{"hasNextPage": true, "endCursor": cursor(`[{"c":"a","t":"String","v":"2"}]`)},
+		}},
+		result.Data,
+	)
+
+	// Cursors from a query sorted differently are rejected, including one
+	// sorted on the same columns the other way.
+	for _, tt := range []struct {
+		sort   string
+		cursor string
+	}{
+		{sort: `{b: asc}`, cursor: `[{"c":"a","t":"String","v":"2"}]`},
+		{sort: `{b: desc}`, cursor: `[{"c":"b","t":"String","v":"y"},{"c":"a","t":"String","v":"2"}]`},
+		{sort: `{b: asc, c: asc}`, cursor: `[{"c":"c","t":"String","v":"z"},{"c":"b","t":"String","v":"y"},{"c":"a","t":"String","v":"2"}]`},
+	} {
+		result = graphql.Do(graphql.Params{
+			Schema:        *schema,
+			RequestString: fmt.Sprintf(`{ foo_connection(sort: %s, after: %q) { edges { cursor } } }`, tt.sort, cursor(tt.cursor)),
+		})
+		require.Len(t, result.Errors, 1, tt.sort)
+		assert.Equal(t, "invalid cursor, it's from a query with a different sort", result.Errors[0].Message)
+	}
+}
+
+// hasPreviousPage looks for a row before the cursor, and only when it's
+// asked for.
+func TestConnection_HasPreviousPage(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		query     string
+		responses []r
+		want      bool
+		queries   []string
+	}{
+		{
+			name:  "first page",
+			query: `{ foo_connection(first: 1) { pageInfo { hasPreviousPage } } }`,
+			want:  false,
+			queries: qs(
+				`SELECT "A" FROM "FOO" ORDER BY "A" ASC NULLS LAST LIMIT ?`,
+			),
+		},
+		{
+			name:      "rows before",
+			query:     fmt.Sprintf(`{ foo_connection(first: 1, after: %q) { pageInfo { hasPreviousPage } } }`, cursor(`[{"c":"a","t":"String","v":"2"}]`)),
+			responses: []r{{{"a": "3"}}, {{"1": 1}}},
+			want:      true,
+			queries: qs(
+				`SELECT "A" FROM "FOO" WHERE ("A" > ?) ORDER BY "A" ASC NULLS LAST LIMIT ?`,
+				`SELECT 1 FROM "FOO" WHERE NOT COALESCE(("A" > ?), FALSE) LIMIT ?`,
+			),
+		},
+		{
+			// The row the cursor was made from has gone, and nothing else
+			// comes before it.
+			name:      "nothing before",
+			query:     fmt.Sprintf(`{ foo_connection(first: 1, after: %q) { pageInfo { hasPreviousPage } } }`, cursor(`[{"c":"a","t":"String","v":"2"}]`)),
+			responses: []r{{{"a": "3"}}, {}},
+			want:      false,
+			queries: qs(
+				`SELECT "A" FROM "FOO" WHERE ("A" > ?) ORDER BY "A" ASC NULLS LAST LIMIT ?`,
+				`SELECT 1 FROM "FOO" WHERE NOT COALESCE(("A" > ?), FALSE) LIMIT ?`,
+			),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := &mockClient{responses: tt.responses}
+			schema, err := gql.BuildSchema(mc, schema, gql.Config{})
+			require.NoError(t, err)
+
+			result := graphql.Do(graphql.Params{Schema: *schema, RequestString: tt.query})
+			require.Empty(t, result.Errors)
+			assert.Equal(t,
+				map[string]any{"foo_connection": map[string]any{"pageInfo": map[string]any{"hasPreviousPage": tt.want}}},
+				result.Data,
+			)
+			assert.Equal(t, tt.queries, mc.queries)
+		})
+	}
+}
+
+// Cursors carry the type of each value, so the next page binds the same
+// types the warehouse returned, whatever the driver handed back.
+func TestConnection_CursorTypes(t *testing.T) {
+	placed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mc := &mockClient{responses: []r{
+		// lib/pq returns NUMERIC as text.
+		{{"id": int64(3000000000), "amount": []byte("12.50"), "placed_at": placed}},
+		{},
+	}}
+	schema, err := gql.BuildSchema(mc, schema, gql.Config{})
+	require.NoError(t, err)
+
+	query := `query($after: String) { orders_connection(first: 1, sort: {placed_at: desc, amount: asc}, after: $after) {
+		edges { node { id } } pageInfo { endCursor } } }`
+	result := graphql.Do(graphql.Params{Schema: *schema, RequestString: query})
+	require.Empty(t, result.Errors)
+	end := result.Data.(map[string]any)["orders_connection"].(map[string]any)["pageInfo"].(map[string]any)["endCursor"]
+
+	result = graphql.Do(graphql.Params{Schema: *schema, RequestString: query, VariableValues: map[string]any{"after": end}})
+	require.Empty(t, result.Errors)
+	require.Len(t, mc.args, 2)
+	// Sort keys go in the model's column order, amount and then placed_at.
+	assert.Equal(t, a(12.5, 12.5, placed, 12.5, placed, int64(3000000000), int64(2)), mc.args[1])
+}
+
+func cursor(values string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(values))
+}
+
+func TestMaxRows(t *testing.T) {
+	mc := &mockClient{responses: []r{{{"a": 1}, {"a": 2}, {"a": 3}}}}
+	schema, err := gql.BuildSchema(mc, schema, gql.Config{MaxRows: 2})
+	require.NoError(t, err)
+
+	result := graphql.Do(graphql.Params{
+		Schema:        *schema,
+		RequestString: `{ foo { a } }`,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "query returned more than 2 rows", result.Errors[0].Message)
+}
+
+// Limits over the cap are rejected before anything is run. The default
+// limits come down to the cap, see TestMaxRows.
+func TestMaxRows_Limits(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{query: `{ foo(limit: 11) { a } }`, want: "limit can be at most 10"},
+		{query: `{ foo_connection(first: 10) { edges { cursor } } }`, want: "first can be at most 9"},
+		{query: `{ orders_aggregate(group_by: [status], limit: 11) { count } }`, want: "limit can be at most 10"},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			mc := &mockClient{}
+			schema, err := gql.BuildSchema(mc, schema, gql.Config{MaxRows: 10})
+			require.NoError(t, err)
+
+			result := graphql.Do(graphql.Params{Schema: *schema, RequestString: tt.query})
+			require.Len(t, result.Errors, 1)
+			assert.Equal(t, tt.want, result.Errors[0].Message)
+			assert.Empty(t, mc.queries)
+		})
+	}
+}
+
 func qs(queries ...string) []string {
 	return queries
 }
+
+// The args for each query
+func as(args ...[]any) [][]any {
+	return args
+}
+
+// The args for a single query
+func a(args ...any) []any {
+	return args
+}
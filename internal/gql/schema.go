@@ -2,20 +2,32 @@ package gql
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/graph-gophers/dataloader"
 	"github.com/graphql-go/graphql"
 
-	"github.com/supasheet/dal/internal/dal"
-	"github.com/supasheet/dal/internal/warehouse"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
 )
 
-func BuildSchema(wc warehouse.Client, s dal.Schema) (*graphql.Schema, error) {
+// Configures how the schema runs queries against the warehouse.
+type Config struct {
+	// Cancels any warehouse query that runs for longer than this. Zero means
+	// no timeout.
+	QueryTimeout time.Duration
+	// Fails any warehouse query that returns more than this many rows, which
+	// bounds the memory a single request can use. Zero means no limit.
+	MaxRows int
+}
+
+func BuildSchema(wc warehouse.Client, s dal.Schema, cfg Config) (*graphql.Schema, error) {
 	sb := &schemaBuilder{
 		schema:  s,
 		wc:      wc,
+		exec:    &executor{wc: wc, timeout: cfg.QueryTimeout, maxRows: cfg.MaxRows},
+		dialect: newSqlDialect(wc),
 		types:   make(map[string]*graphql.Object),
-		loaders: make(map[string]*dataloader.Loader),
 	}
 	return sb.build()
 }
@@ -23,8 +35,9 @@ func BuildSchema(wc warehouse.Client, s dal.Schema) (*graphql.Schema, error) {
 type schemaBuilder struct {
 	schema  dal.Schema
 	wc      warehouse.Client
+	exec    *executor
+	dialect sqlDialect
 	types   map[string]*graphql.Object
-	loaders map[string]*dataloader.Loader
 }
 
 // This builds the graphql schema.
@@ -33,10 +46,12 @@ func (sb *schemaBuilder) build() (*graphql.Schema, error) {
 
 	fields := make(graphql.Fields)
 	for name, model := range sb.schema {
+		filter := buildFilter(model)
+		sort := buildSort(model)
 		fields[name] = &graphql.Field{
 			Description: model.Description,
 			Type:        graphql.NewList(sb.types[name]),
-			Resolve:     buildResolver(sb.wc, model),
+			Resolve:     buildResolver(sb.exec, sb.dialect, model),
 			Args: graphql.FieldConfigArgument{
 				"limit": &graphql.ArgumentConfig{
 					Type:        graphql.Int,
@@ -46,13 +61,21 @@ func (sb *schemaBuilder) build() (*graphql.Schema, error) {
 					Type:        graphql.Int,
 					Description: "Offset",
 				},
-				"filter": buildFilter(model),
-				"sort":   buildSort(model),
+				"filter": filter,
+				"sort":   sort,
 			},
 		}
+		// Cursors need a primary key to tell rows apart.
+		if model.PrimaryKey != "" {
+			fields[name+"_connection"] = sb.buildConnection(model, filter, sort)
+		}
+		fields[name+"_aggregate"] = sb.buildAggregate(model, filter)
 	}
 	rootQuery := graphql.ObjectConfig{Name: "RootQuery", Fields: fields}
-	schemaConfig := graphql.SchemaConfig{Query: graphql.NewObject(rootQuery)}
+	schemaConfig := graphql.SchemaConfig{
+		Query:      graphql.NewObject(rootQuery),
+		Extensions: []graphql.Extension{loadersExtension{}},
+	}
 
 	schema, err := graphql.NewSchema(schemaConfig)
 	if err != nil {
@@ -71,11 +94,17 @@ func (sb *schemaBuilder) resolveTypes() {
 		// and create a field for each one.
 		fields := make(graphql.Fields)
 		for _, col := range model.Columns {
+			key := recordKey(col.Name)
 			fields[col.Name] = &graphql.Field{
 				// Map the dal type to the appropriate GrahpQL type.
 				Type: mapScalarType(col.Type),
 				// Bring through the description from the model.
 				Description: col.Description,
+				// The column name may not be lower case, but the key in the
+				// record will be.
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(warehouse.Record)[key], nil
+				},
 			}
 		}
 
@@ -126,9 +155,13 @@ func (sb *schemaBuilder) resolveForeignKeys() {
 			// Get the type for the related model
 			rel := sb.types[fk.Model]
 
-			// For each one we create a loader that filters the target
-			// according to the join key
-			loader := buildOneToManyLoader(sb.wc, fk.Model, fk.On)
+			// For each one there's a loader that filters the target according
+			// to the join key. Each request gets its own.
+			fk := fk
+			loader := fmt.Sprintf("%s.%s", fk.Model, fk.On)
+			build := func() *dataloader.Loader {
+				return buildOneToManyLoader(sb.exec, sb.dialect, sb.schema[fk.Model], fk.On)
+			}
 
 			// And then we add a field for the relationship.
 			t.AddFieldConfig(fk.Model, &graphql.Field{
@@ -136,8 +169,10 @@ func (sb *schemaBuilder) resolveForeignKeys() {
 				Description: fmt.Sprintf("Associated %s", fk.Model),
 				Resolve: func(p graphql.ResolveParams) (any, error) {
 					source := p.Source.(warehouse.Record)
-					rawKey := source[model.PrimaryKey]
-					thunk := loader.Load(p.Context, NewResolverKey(rawKey))
+					rawKey := source[recordKey(model.PrimaryKey)]
+					key := NewResolverKey(rawKey)
+					ctx := resolveContext(p)
+					thunk := requestLoader(ctx, loader, build).Load(ctx, key)
 					return func() (any, error) {
 						return thunk()
 					}, nil
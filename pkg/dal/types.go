@@ -56,15 +56,27 @@ type Model struct {
 	Name        string
 	Description string
 	PrimaryKey  string
+	Relation    Relation
 	Columns     []Column
 	ForeignKeys []ForeignKey
 
 	schema Schema
 }
 
-// Adds a new column to the model.
-func (m *Model) AddColumn(name, description string, t Scalar) {
-	m.Columns = append(m.Columns, Column{Name: name, Description: description, Type: t})
+// Adds a new column to the model. The identifier is the column's name in the
+// warehouse, if it's known.
+func (m *Model) AddColumn(name, identifier, description string, t Scalar) {
+	m.Columns = append(m.Columns, Column{Name: name, Identifier: identifier, Description: description, Type: t})
+}
+
+// Returns the named column, if the model has it.
+func (m *Model) Column(name string) (Column, bool) {
+	for _, col := range m.Columns {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return Column{}, false
 }
 
 // Adds a new foreign key to the model. It requires that the model already
@@ -77,8 +89,22 @@ func (m *Model) AddForeignKey(model, on string) error {
 	return fmt.Errorf("cannot create foreign key: %s is not a valid model: %w", model, ErrNoSuchModel)
 }
 
+// Where a model physically lives in the warehouse. Any of the parts may be
+// empty, in which case the warehouse's defaults apply.
+type Relation struct {
+	Database string
+	Schema   string
+	// The name of the table or view, which is the model's alias if it has
+	// one.
+	Identifier string
+}
+
 type Column struct {
-	Name        string
+	Name string
+	// The column's name in the warehouse, exactly as the warehouse reports
+	// it. This can differ from the name in the dbt project by case, and is
+	// empty if it isn't known.
+	Identifier  string
 	Description string
 	Type        Scalar
 }
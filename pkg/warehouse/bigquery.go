@@ -0,0 +1,226 @@
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+func init() {
+	// BigQuery quotes identifiers with backticks, and escapes quotes inside
+	// string literals with a backslash rather than by doubling them up. Column
+	// names are case insensitive and table names are case sensitive, so
+	// nothing is folded.
+	opts := goqu.DefaultDialectOptions()
+	opts.QuoteRune = '`'
+	opts.EscapedRunes = map[rune][]byte{
+		'\'': []byte("\\'"),
+		'\\': []byte("\\\\"),
+	}
+	Register("bigquery", Adapter{
+		New:               newBigQueryFromOutput,
+		Dialect:           opts,
+		InformationSchema: bigqueryInformationSchema,
+	})
+}
+
+// BigQuery keeps an INFORMATION_SCHEMA per dataset rather than per project.
+func bigqueryInformationSchema(rel dal.Relation, _ func(string) string) exp.Expression {
+	dataset := rel.Schema
+	if rel.Database != "" {
+		dataset = rel.Database + "." + dataset
+	}
+	return goqu.T("INFORMATION_SCHEMA.COLUMNS").Schema(dataset)
+}
+
+type BigQueryCredentials struct {
+	// One of the dbt-bigquery auth methods: oauth, service-account or
+	// service-account-json. Leaving it empty when an APIEndpoint is set skips
+	// authentication altogether, which is what the BigQuery emulator expects.
+	Method      string         `json:"method"`
+	Project     string         `json:"project"`
+	Dataset     string         `json:"dataset"`
+	Keyfile     string         `json:"keyfile"`
+	KeyfileJSON map[string]any `json:"keyfile_json"`
+	Location    string         `json:"location"`
+	APIEndpoint string         `json:"api_endpoint"`
+}
+
+// Builds the options used to create the BigQuery client.
+func (bqc BigQueryCredentials) ClientOptions() ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	if bqc.APIEndpoint != "" {
+		opts = append(opts, option.WithEndpoint(bqc.APIEndpoint))
+	}
+
+	switch bqc.Method {
+	case "oauth":
+		// Application default credentials, nothing to configure.
+	case "service-account":
+		opts = append(opts, option.WithCredentialsFile(bqc.Keyfile))
+	case "service-account-json":
+		b, err := json.Marshal(bqc.KeyfileJSON)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithCredentialsJSON(b))
+	case "":
+		if bqc.APIEndpoint == "" {
+			return nil, fmt.Errorf("bigquery method is required")
+		}
+		opts = append(opts, option.WithoutAuthentication())
+	default:
+		return nil, fmt.Errorf("bigquery method %s is not supported", bqc.Method)
+	}
+
+	return opts, nil
+}
+
+type BigQueryClient struct {
+	client *bigquery.Client
+	creds  BigQueryCredentials
+}
+
+func NewBigQuery(bqc BigQueryCredentials) *BigQueryClient {
+	return &BigQueryClient{creds: bqc}
+}
+
+func newBigQueryFromOutput(output map[string]any) (Client, error) {
+	var bqc BigQueryCredentials
+	if err := decodeOutput(output, &bqc); err != nil {
+		return nil, err
+	}
+	// dbt treats database and schema as aliases for project and dataset.
+	if bqc.Project == "" {
+		bqc.Project, _ = output["database"].(string)
+	}
+	if bqc.Dataset == "" {
+		bqc.Dataset, _ = output["schema"].(string)
+	}
+	return NewBigQuery(bqc), nil
+}
+
+func (bc *BigQueryClient) Connect() error {
+	opts, err := bc.creds.ClientOptions()
+	if err != nil {
+		return err
+	}
+	client, err := bigquery.NewClient(context.Background(), bc.creds.Project, opts...)
+	if err != nil {
+		return err
+	}
+
+	bc.client = client
+	return nil
+}
+
+func (bc *BigQueryClient) Run(ctx context.Context, query string, args ...any) (Records, error) {
+	rows, err := bc.Stream(ctx, query, args...)
+	if err != nil {
+		return Records{}, err
+	}
+	return Collect(rows)
+}
+
+func (bc *BigQueryClient) Stream(ctx context.Context, query string, args ...any) (Rows, error) {
+	// Same as the database/sql clients, no connection means no results.
+	if bc.client == nil {
+		return FromRecords(Records{}), nil
+	}
+
+	q := bc.client.Query(query)
+	// Unqualified model names are resolved against the profile's dataset.
+	q.DefaultProjectID = bc.creds.Project
+	q.DefaultDatasetID = bc.creds.Dataset
+	q.Location = bc.creds.Location
+	// The dialect uses ? placeholders, which are positional parameters.
+	for _, arg := range args {
+		q.Parameters = append(q.Parameters, bigquery.QueryParameter{Value: arg})
+	}
+
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &bigqueryRows{it: it}, nil
+}
+
+func (bc *BigQueryClient) MapType(t string) dal.Scalar {
+	return bigqueryDataTypeMatcher.match(t)
+}
+
+func (bc *BigQueryClient) Dialect() string {
+	return "bigquery"
+}
+
+// The BigQuery client hands back its own types for some values. These are
+// converted to the types the GraphQL layer knows how to serialise.
+func bigqueryValue(v bigquery.Value) any {
+	switch v := v.(type) {
+	case civil.Date:
+		return v.In(time.UTC)
+	case civil.DateTime:
+		return v.In(time.UTC)
+	case civil.Time:
+		return v.String()
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+// Adapts the BigQuery row iterator, which fetches results a page at a time.
+type bigqueryRows struct {
+	it     *bigquery.RowIterator
+	record Record
+	err    error
+}
+
+func (br *bigqueryRows) Next() bool {
+	if br.err != nil {
+		return false
+	}
+	var row map[string]bigquery.Value
+	err := br.it.Next(&row)
+	if err == iterator.Done {
+		return false
+	}
+	if err != nil {
+		br.err = err
+		return false
+	}
+
+	br.record = make(Record, len(row))
+	for c, v := range row {
+		br.record[strings.ToLower(c)] = bigqueryValue(v)
+	}
+	return true
+}
+
+func (br *bigqueryRows) Record() Record { return br.record }
+func (br *bigqueryRows) Err() error     { return br.err }
+func (br *bigqueryRows) Close() error   { return nil }
+
+var bigqueryDataTypeMatcher = &dataTypeMatcher{
+	id:       regexp.MustCompile("a^"),
+	int:      regexp.MustCompile("(?i)^(INT64|INT|INTEGER|SMALLINT|BIGINT|TINYINT|BYTEINT)$"),
+	float:    regexp.MustCompile("(?i)^(FLOAT64|FLOAT|NUMERIC|BIGNUMERIC|DECIMAL|BIGDECIMAL)"),
+	boolean:  regexp.MustCompile("(?i)^(BOOL)"),
+	string:   regexp.MustCompile("(?i)^(STRING)"),
+	dateTime: regexp.MustCompile("(?i)^(TIMESTAMP|DATETIME|DATE)$"),
+}
@@ -0,0 +1,57 @@
+package warehouse_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+func TestBigQueryClientOptions(t *testing.T) {
+	type tc struct {
+		creds warehouse.BigQueryCredentials
+		opts  int
+		err   bool
+	}
+	cases := map[string]tc{
+		"oauth":           {warehouse.BigQueryCredentials{Method: "oauth"}, 0, false},
+		"service_account": {warehouse.BigQueryCredentials{Method: "service-account", Keyfile: "key.json"}, 1, false},
+		"keyfile_json":    {warehouse.BigQueryCredentials{Method: "service-account-json", KeyfileJSON: map[string]any{"type": "service_account"}}, 1, false},
+		"emulator":        {warehouse.BigQueryCredentials{APIEndpoint: "http://localhost:9050"}, 2, false},
+		"oauth_endpoint":  {warehouse.BigQueryCredentials{Method: "oauth", APIEndpoint: "http://localhost:9050"}, 1, false},
+		"no_method":       {warehouse.BigQueryCredentials{}, 0, true},
+		"bad_method":      {warehouse.BigQueryCredentials{Method: "oauth-secrets"}, 0, true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts, err := c.creds.ClientOptions()
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, opts, c.opts)
+		})
+	}
+}
+
+func TestBigQueryMapType(t *testing.T) {
+	client := warehouse.NewBigQuery(warehouse.BigQueryCredentials{})
+	cases := map[string]dal.Scalar{
+		"INT64":         dal.Int,
+		"FLOAT64":       dal.Float,
+		"NUMERIC":       dal.Float,
+		"BOOL":          dal.Boolean,
+		"STRING":        dal.String,
+		"TIMESTAMP":     dal.DateTime,
+		"DATE":          dal.DateTime,
+		"ARRAY<INT64>":  dal.String,
+		"STRUCT<a INT>": dal.String,
+	}
+	for raw, expectation := range cases {
+		t.Run(raw, func(t *testing.T) {
+			assert.Equal(t, expectation, client.MapType(raw))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+type Client interface {
+	// Initialises the data warehosue connection.
+	Connect() error
+
+	// Runs a query and pulls all of the results back into memory. The
+	// context is passed down to the driver, so cancelling it cancels the
+	// query. Any args are bound to the query's placeholders, which use the
+	// syntax of the warehouse's dialect.
+	Run(ctx context.Context, query string, args ...any) (Records, error)
+
+	// Runs a query and returns an iterator over the results, which are read
+	// from the warehouse as they are consumed. The caller must close it.
+	Stream(ctx context.Context, query string, args ...any) (Rows, error)
+
+	// Maps a type from the data warehouse's type system to the appropriate dal
+	// type.
+	MapType(string) dal.Scalar
+
+	// The name of the goqu dialect that should be used to generate SQL for
+	// this warehouse.
+	Dialect() string
+}
+
+type queryTagKey struct{}
+
+// Tags the queries run with the context, e.g. with the name of the GraphQL
+// operation that needed them, to attribute warehouse usage. Warehouses that
+// support query tags add it to the tag from the profile.
+func WithQueryTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, queryTagKey{}, tag)
+}
+
+// The tag queries run with the context should have, if any.
+func QueryTag(ctx context.Context) (string, bool) {
+	tag, ok := ctx.Value(queryTagKey{}).(string)
+	return tag, ok && tag != ""
+}
+
+var (
+	// How long to wait before the first retry. It doubles with each attempt.
+	retryBackoff = time.Second
+	// Waits between attempts. The tests swap it out so they don't have to.
+	sleep = time.Sleep
+)
+
+// Calls fn, retrying up to the given number of times, with exponential
+// backoff, for as long as it fails with errors that are retryable.
+func retry(retries int, retryable func(error) bool, fn func() error) error {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+		sleep(backoff)
+		backoff *= 2
+	}
+}
+
+type (
+	Record  map[string]any
+	Records []Record
+)
+
+// Runs a query against a database/sql connection and streams the results.
+func streamQuery(ctx context.Context, db *sql.DB, query string, args []any) (Rows, error) {
+	// If we've not initialised the connection, return an empty result set for
+	// now.
+	// TODO this is clearly not ideal, if the warehouse isn't available the
+	// server shouldn't even bother trying to serve queries and should just let
+	// the caller know what's up.
+	if db == nil {
+		return FromRecords(Records{}), nil
+	}
+
+	// Run the query
+	rs, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSqlRows(rs)
+}
+
+// Runs a query against a database/sql connection and collects the results.
+func runQuery(ctx context.Context, db *sql.DB, query string, args []any) (Records, error) {
+	rows, err := streamQuery(ctx, db, query, args)
+	if err != nil {
+		return Records{}, err
+	}
+	return Collect(rows)
+}
+
+type dataTypeMatcher struct {
+	id       *regexp.Regexp
+	int      *regexp.Regexp
+	float    *regexp.Regexp
+	boolean  *regexp.Regexp
+	string   *regexp.Regexp
+	dateTime *regexp.Regexp
+}
+
+func (dtm *dataTypeMatcher) match(raw string) dal.Scalar {
+	switch {
+	case dtm.id.MatchString(raw):
+		return dal.ID
+	case dtm.int.MatchString(raw):
+		return dal.Int
+	case dtm.float.MatchString(raw):
+		return dal.Float
+	case dtm.boolean.MatchString(raw):
+		return dal.Boolean
+	case dtm.string.MatchString(raw):
+		return dal.String
+	case dtm.dateTime.MatchString(raw):
+		return dal.DateTime
+	default:
+		return dal.String
+	}
+}
@@ -0,0 +1,49 @@
+package warehouse_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+func TestRetry(t *testing.T) {
+	errRetryable := errors.New("retryable")
+	errFatal := errors.New("fatal")
+	retryable := func(err error) bool { return errors.Is(err, errRetryable) }
+
+	cases := []struct {
+		name     string
+		retries  int
+		errs     []error
+		want     error
+		attempts int
+	}{
+		{name: "succeeds", retries: 3, errs: []error{nil}, attempts: 1},
+		{name: "succeeds on a retry", retries: 3, errs: []error{errRetryable, errRetryable, nil}, attempts: 3},
+		{name: "runs out of retries", retries: 2, errs: []error{errRetryable, errRetryable, errRetryable, nil}, want: errRetryable, attempts: 3},
+		{name: "no retries", retries: 0, errs: []error{errRetryable, nil}, want: errRetryable, attempts: 1},
+		{name: "not retryable", retries: 3, errs: []error{errFatal, nil}, want: errFatal, attempts: 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			waits := warehouse.StubSleep(t)
+			attempts := 0
+			err := warehouse.Retry(c.retries, retryable, func() error {
+				attempts++
+				return c.errs[attempts-1]
+			})
+			assert.Equal(t, c.want, err)
+			assert.Equal(t, c.attempts, attempts)
+			assert.Len(t, *waits, c.attempts-1)
+		})
+	}
+}
+
+func TestRetry_Backoff(t *testing.T) {
+	waits := warehouse.StubSleep(t)
+	warehouse.Retry(4, func(error) bool { return true }, func() error { return errors.New("down") })
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}, *waits)
+}
@@ -0,0 +1,111 @@
+//go:build cgo
+
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9/dialect/postgres"
+	"github.com/marcboeker/go-duckdb"
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+// The DuckDB driver is cgo, so it's only in builds with cgo enabled. See
+// duckdb_nocgo.go for the rest.
+func init() {
+	// DuckDB speaks the postgres dialect closely enough for our purposes.
+	// Identifiers are case insensitive, even when quoted, so there's no need
+	// to fold them.
+	Register("duckdb", Adapter{New: newDuckDBFromOutput, Dialect: postgres.DialectOptions()})
+}
+
+type DuckDBCredentials struct {
+	Path   string `json:"path"`
+	Schema string `json:"schema"`
+}
+
+func (dc DuckDBCredentials) ConnString() (string, error) {
+	// An empty path (or :memory:) gives us an in memory database, which can't
+	// be opened read only.
+	if dc.Path == "" || dc.Path == ":memory:" {
+		return "", nil
+	}
+	// dal never writes to the warehouse, so the file is opened read only.
+	// That lets several readers share it, but DuckDB still won't let another
+	// process open it for writing while we have it open, so dbt can't build
+	// into the same file while dal is serving it.
+	params := url.Values{}
+	params.Set("access_mode", "read_only")
+	return dc.Path + "?" + params.Encode(), nil
+}
+
+type DuckDBClient struct {
+	db    *sql.DB
+	creds DuckDBCredentials
+}
+
+func NewDuckDB(dc DuckDBCredentials) *DuckDBClient {
+	return &DuckDBClient{creds: dc}
+}
+
+func newDuckDBFromOutput(output map[string]any) (Client, error) {
+	var dc DuckDBCredentials
+	if err := decodeOutput(output, &dc); err != nil {
+		return nil, err
+	}
+	return NewDuckDB(dc), nil
+}
+
+func (dc *DuckDBClient) Connect() error {
+	dsn, err := dc.creds.ConnString()
+	if err != nil {
+		return err
+	}
+
+	// DuckDB settings are per connection, so the schema has to be set on each
+	// one the pool opens.
+	connector, err := duckdb.NewConnector(dsn, func(execer driver.ExecerContext) error {
+		if dc.creds.Schema == "" {
+			return nil
+		}
+		_, err := execer.ExecContext(context.Background(), fmt.Sprintf("SET schema = '%s'", strings.ReplaceAll(dc.creds.Schema, "'", "''")), nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	dc.db = sql.OpenDB(connector)
+	return nil
+}
+
+func (dc *DuckDBClient) Run(ctx context.Context, query string, args ...any) (Records, error) {
+	return runQuery(ctx, dc.db, query, args)
+}
+
+func (dc *DuckDBClient) Stream(ctx context.Context, query string, args ...any) (Rows, error) {
+	return streamQuery(ctx, dc.db, query, args)
+}
+
+func (dc *DuckDBClient) MapType(t string) dal.Scalar {
+	return duckdbDataTypeMatcher.match(t)
+}
+
+func (dc *DuckDBClient) Dialect() string {
+	return "duckdb"
+}
+
+var duckdbDataTypeMatcher = &dataTypeMatcher{
+	id:       regexp.MustCompile("a^"),
+	int:      regexp.MustCompile("(?i)^(TINYINT|SMALLINT|INTEGER|BIGINT|HUGEINT|UTINYINT|USMALLINT|UINTEGER|UBIGINT|INT[1248]?|LONG|SHORT|SIGNED)\\b"),
+	float:    regexp.MustCompile("(?i)^(DOUBLE|FLOAT[48]?|REAL|DECIMAL|NUMERIC)"),
+	boolean:  regexp.MustCompile("(?i)^(BOOL|LOGICAL)"),
+	string:   regexp.MustCompile("(?i)^(VARCHAR|CHAR|BPCHAR|TEXT|STRING|UUID|JSON|ENUM)"),
+	dateTime: regexp.MustCompile("(?i)^(TIMESTAMP|DATETIME|DATE|TIME)"),
+}
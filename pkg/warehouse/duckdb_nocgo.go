@@ -0,0 +1,22 @@
+//go:build !cgo
+
+package warehouse
+
+import (
+	"errors"
+
+	"github.com/doug-martin/goqu/v9/dialect/postgres"
+)
+
+// The DuckDB driver needs cgo. Builds without it, like the release binaries,
+// still know about the adapter so they can say why it isn't available.
+var ErrDuckDBNeedsCgo = errors.New("duckdb is not available in this build of dal, it needs to be built with CGO_ENABLED=1")
+
+func init() {
+	Register("duckdb", Adapter{
+		New: func(map[string]any) (Client, error) {
+			return nil, ErrDuckDBNeedsCgo
+		},
+		Dialect: postgres.DialectOptions(),
+	})
+}
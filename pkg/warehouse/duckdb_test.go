@@ -0,0 +1,105 @@
+//go:build cgo
+
+package warehouse_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+func TestDuckDBConnString(t *testing.T) {
+	cases := map[string]string{
+		"":           "",
+		":memory:":   "",
+		"dev.duckdb": "dev.duckdb?access_mode=read_only",
+	}
+	for path, expectation := range cases {
+		t.Run(path, func(t *testing.T) {
+			dsn, err := warehouse.DuckDBCredentials{Path: path}.ConnString()
+			require.NoError(t, err)
+			assert.Equal(t, expectation, dsn)
+		})
+	}
+}
+
+func TestDuckDBMapType(t *testing.T) {
+	client := warehouse.NewDuckDB(warehouse.DuckDBCredentials{})
+	cases := map[string]dal.Scalar{
+		"INTEGER":                  dal.Int,
+		"BIGINT":                   dal.Int,
+		"HUGEINT":                  dal.Int,
+		"DOUBLE":                   dal.Float,
+		"DECIMAL(18,3)":            dal.Float,
+		"BOOLEAN":                  dal.Boolean,
+		"VARCHAR":                  dal.String,
+		"TIMESTAMP":                dal.DateTime,
+		"TIMESTAMP WITH TIME ZONE": dal.DateTime,
+		"DATE":                     dal.DateTime,
+		"INTERVAL":                 dal.String,
+	}
+	for raw, expectation := range cases {
+		t.Run(raw, func(t *testing.T) {
+			assert.Equal(t, expectation, client.MapType(raw))
+		})
+	}
+}
+
+func TestDuckDBRun(t *testing.T) {
+	client := warehouse.NewDuckDB(warehouse.DuckDBCredentials{Schema: "main"})
+	require.NoError(t, client.Connect())
+
+	rs, err := client.Run(context.Background(), "select 0 as A, 'x' as b union all select 1 as a, 'y' as b order by a")
+	require.NoError(t, err)
+
+	expectation := warehouse.Records{
+		warehouse.Record{"a": int32(0), "b": "x"},
+		warehouse.Record{"a": int32(1), "b": "y"},
+	}
+	assert.Equal(t, expectation, rs)
+}
+
+func TestDuckDBRunArgs(t *testing.T) {
+	client := warehouse.NewDuckDB(warehouse.DuckDBCredentials{})
+	require.NoError(t, client.Connect())
+
+	rs, err := client.Run(context.Background(), "select $1 as a, $2 as b", "x' or 1=1 --", int64(2))
+	require.NoError(t, err)
+
+	expectation := warehouse.Records{
+		warehouse.Record{"a": "x' or 1=1 --", "b": int64(2)},
+	}
+	assert.Equal(t, expectation, rs)
+}
+
+func TestDuckDBStream(t *testing.T) {
+	client := warehouse.NewDuckDB(warehouse.DuckDBCredentials{})
+	require.NoError(t, client.Connect())
+
+	rows, err := client.Stream(context.Background(), "select range as n from range(10000)")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	n := int64(0)
+	for rows.Next() {
+		assert.Equal(t, warehouse.Record{"n": n}, rows.Record())
+		n++
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, int64(10000), n)
+}
+
+func TestColumns_DuckDB(t *testing.T) {
+	client := warehouse.NewDuckDB(warehouse.DuckDBCredentials{})
+	require.NoError(t, client.Connect())
+	_, err := client.Run(context.Background(), "create table orders (id integer, Total double)")
+	require.NoError(t, err)
+
+	columns, err := warehouse.Columns(context.Background(), client, dal.Relation{Database: "memory", Schema: "main", Identifier: "orders"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"id": "INTEGER", "Total": "DOUBLE"}, columns)
+}
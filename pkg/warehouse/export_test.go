@@ -0,0 +1,31 @@
+package warehouse
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// Hooks into the unexported parts of the package for its tests.
+
+var Retry = retry
+
+// Records the waits between retries instead of waiting, until the test ends.
+func StubSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	old := sleep
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = old })
+	return &waits
+}
+
+func SetQueryTagResetTimeout(t *testing.T, d time.Duration) {
+	old := queryTagResetTimeout
+	queryTagResetTimeout = d
+	t.Cleanup(func() { queryTagResetTimeout = old })
+}
+
+func (sc *SnowflakeClient) Retryable(err error) bool { return sc.retryable(err) }
+
+// Connects the client to a database of the test's choosing.
+func (sc *SnowflakeClient) SetDB(db *sql.DB) { sc.db = db }
@@ -0,0 +1,58 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+// Lists the columns of a relation straight from the warehouse's
+// information_schema, mapped to their data types. The names are as the
+// warehouse reports them.
+func Columns(ctx context.Context, c Client, rel dal.Relation) (map[string]string, error) {
+	adaptersMu.RLock()
+	a := adapters[c.Dialect()]
+	adaptersMu.RUnlock()
+
+	fold := func(id string) string { return Fold(c.Dialect(), id) }
+	from := a.InformationSchema
+	if from == nil {
+		from = informationSchema
+	}
+
+	query, args, err := goqu.Dialect(c.Dialect()).
+		From(from(rel, fold)).
+		Select(goqu.C(fold("column_name")), goqu.C(fold("data_type"))).
+		Where(goqu.Ex{
+			fold("table_schema"): rel.Schema,
+			fold("table_name"):   rel.Identifier,
+		}).
+		Prepared(true).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := c.Run(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to introspect %s.%s.%s: %w", rel.Database, rel.Schema, rel.Identifier, err)
+	}
+	columns := make(map[string]string, len(rs))
+	for _, r := range rs {
+		name, _ := r["column_name"].(string)
+		dataType, _ := r["data_type"].(string)
+		columns[name] = dataType
+	}
+	return columns, nil
+}
+
+// The standard information_schema.columns view of the relation's database.
+func informationSchema(rel dal.Relation, fold func(string) string) exp.Expression {
+	if rel.Database == "" {
+		return goqu.S(fold("information_schema")).Table(fold("columns"))
+	}
+	return exp.NewIdentifierExpression(rel.Database, fold("information_schema"), fold("columns"))
+}
@@ -0,0 +1,60 @@
+package warehouse_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+// Records the query it's asked to run, and answers it with canned records.
+type recordingClient struct {
+	fakeClient
+	dialect string
+	query   string
+	args    []any
+	records warehouse.Records
+}
+
+func (rc *recordingClient) Run(_ context.Context, query string, args ...any) (warehouse.Records, error) {
+	rc.query, rc.args = query, args
+	return rc.records, nil
+}
+func (rc *recordingClient) Dialect() string { return rc.dialect }
+
+func TestColumns_SQL(t *testing.T) {
+	rel := dal.Relation{Database: "analytics", Schema: "marts", Identifier: "orders"}
+	cases := []struct {
+		dialect string
+		want    string
+	}{
+		{
+			"snowflake",
+			`SELECT "COLUMN_NAME", "DATA_TYPE" FROM "analytics"."INFORMATION_SCHEMA"."COLUMNS" WHERE (("TABLE_NAME" = ?) AND ("TABLE_SCHEMA" = ?))`,
+		},
+		{
+			"postgres",
+			`SELECT "column_name", "data_type" FROM "analytics"."information_schema"."columns" WHERE (("table_name" = $1) AND ("table_schema" = $2))`,
+		},
+		{
+			"bigquery",
+			"SELECT `column_name`, `data_type` FROM `analytics.marts`.`INFORMATION_SCHEMA.COLUMNS` WHERE ((`table_name` = ?) AND (`table_schema` = ?))",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.dialect, func(t *testing.T) {
+			rc := &recordingClient{
+				dialect: c.dialect,
+				records: warehouse.Records{{"column_name": "ID", "data_type": "NUMBER"}},
+			}
+			columns, err := warehouse.Columns(context.Background(), rc, rel)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, rc.query)
+			assert.Equal(t, []any{"orders", "marts"}, rc.args)
+			assert.Equal(t, map[string]string{"ID": "NUMBER"}, columns)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9/dialect/postgres"
+	_ "github.com/lib/pq"
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+func init() {
+	// Postgres lower cases unquoted identifiers.
+	Register("postgres", Adapter{
+		New:     newPostgresFromOutput,
+		Dialect: postgres.DialectOptions(),
+		Fold:    strings.ToLower,
+	})
+}
+
+type PostgresCredentials struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	DBName   string `json:"dbname"`
+	Schema   string `json:"schema"`
+	SSLMode  string `json:"sslmode"`
+}
+
+func (pc PostgresCredentials) ConnString() (string, error) {
+	host := pc.Host
+	if pc.Port != 0 {
+		host = host + ":" + strconv.Itoa(pc.Port)
+	}
+
+	params := url.Values{}
+	if pc.SSLMode != "" {
+		params.Set("sslmode", pc.SSLMode)
+	}
+	// Unqualified model names are resolved against the profile's schema, the
+	// same way dbt does it.
+	if pc.Schema != "" {
+		params.Set("search_path", pc.Schema)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(pc.User, pc.Password),
+		Host:     host,
+		Path:     "/" + pc.DBName,
+		RawQuery: params.Encode(),
+	}
+	return dsn.String(), nil
+}
+
+type PostgresClient struct {
+	db    *sql.DB
+	creds PostgresCredentials
+}
+
+func NewPostgres(pc PostgresCredentials) *PostgresClient {
+	return &PostgresClient{creds: pc}
+}
+
+func newPostgresFromOutput(output map[string]any) (Client, error) {
+	pc, err := postgresCredentialsFromOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgres(pc), nil
+}
+
+// Postgres and Redshift targets share the same connection settings.
+func postgresCredentialsFromOutput(output map[string]any) (PostgresCredentials, error) {
+	var pc PostgresCredentials
+	if err := decodeOutput(output, &pc); err != nil {
+		return PostgresCredentials{}, err
+	}
+	// dbt accepts either pass or password for these targets.
+	if pc.Password == "" {
+		if pass, ok := output["pass"].(string); ok {
+			pc.Password = pass
+		}
+	}
+	return pc, nil
+}
+
+func (pc *PostgresClient) Connect() error {
+	dsn, err := pc.creds.ConnString()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+
+	pc.db = db
+	return nil
+}
+
+func (pc *PostgresClient) Run(ctx context.Context, query string, args ...any) (Records, error) {
+	return runQuery(ctx, pc.db, query, args)
+}
+
+func (pc *PostgresClient) Stream(ctx context.Context, query string, args ...any) (Rows, error) {
+	return streamQuery(ctx, pc.db, query, args)
+}
+
+func (pc *PostgresClient) MapType(t string) dal.Scalar {
+	return postgresDataTypeMatcher.match(t)
+}
+
+func (pc *PostgresClient) Dialect() string {
+	return "postgres"
+}
+
+// Postgres type names are matched from the start, otherwise things like
+// "interval" would be picked up as integers.
+var postgresDataTypeMatcher = &dataTypeMatcher{
+	id:       regexp.MustCompile("a^"),
+	int:      regexp.MustCompile("(?i)^(SMALLINT|INTEGER|BIGINT|INT[248]?|SERIAL[48]?|BIGSERIAL|SMALLSERIAL)\\b"),
+	float:    regexp.MustCompile("(?i)^(REAL|DOUBLE PRECISION|FLOAT|NUMERIC|DECIMAL|MONEY)"),
+	boolean:  regexp.MustCompile("(?i)^(BOOL)"),
+	string:   regexp.MustCompile("(?i)^(CHAR|VARCHAR|TEXT|CITEXT|UUID|NAME|JSON)"),
+	dateTime: regexp.MustCompile("(?i)^(TIMESTAMP|DATE|TIME)"),
+}
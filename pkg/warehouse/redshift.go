@@ -0,0 +1,55 @@
+package warehouse
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9/dialect/postgres"
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+func init() {
+	// Redshift is close enough to postgres that the same dialect works. It
+	// lower cases every identifier, quoted or not.
+	Register("redshift", Adapter{
+		New:     newRedshiftFromOutput,
+		Dialect: postgres.DialectOptions(),
+		Fold:    strings.ToLower,
+	})
+}
+
+// Redshift speaks the postgres wire protocol, so the connection handling is
+// shared with the postgres client. Only the type system and identifier
+// folding differ.
+type RedshiftClient struct {
+	*PostgresClient
+}
+
+func NewRedshift(pc PostgresCredentials) *RedshiftClient {
+	return &RedshiftClient{PostgresClient: NewPostgres(pc)}
+}
+
+func newRedshiftFromOutput(output map[string]any) (Client, error) {
+	pc, err := postgresCredentialsFromOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedshift(pc), nil
+}
+
+func (rc *RedshiftClient) MapType(t string) dal.Scalar {
+	return redshiftDataTypeMatcher.match(t)
+}
+
+func (rc *RedshiftClient) Dialect() string {
+	return "redshift"
+}
+
+var redshiftDataTypeMatcher = &dataTypeMatcher{
+	id:       regexp.MustCompile("a^"),
+	int:      regexp.MustCompile("(?i)^(SMALLINT|INTEGER|BIGINT|INT[248]?)$"),
+	float:    regexp.MustCompile("(?i)^(REAL|FLOAT[48]?|DOUBLE PRECISION|NUMERIC|DECIMAL)"),
+	boolean:  regexp.MustCompile("(?i)^(BOOL)"),
+	string:   regexp.MustCompile("(?i)^(CHAR|NCHAR|BPCHAR|VARCHAR|NVARCHAR|TEXT|SUPER|VARBYTE)"),
+	dateTime: regexp.MustCompile("(?i)^(TIMESTAMP|TIMESTAMPTZ|DATE|TIME|TIMETZ)"),
+}
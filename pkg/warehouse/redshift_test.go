@@ -0,0 +1,36 @@
+package warehouse_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+func TestRedshiftMapType(t *testing.T) {
+	client := warehouse.NewRedshift(warehouse.PostgresCredentials{})
+	cases := map[string]dal.Scalar{
+		"integer":                     dal.Int,
+		"bigint":                      dal.Int,
+		"numeric(18,2)":               dal.Float,
+		"double precision":            dal.Float,
+		"boolean":                     dal.Boolean,
+		"character varying(65535)":    dal.String,
+		"varchar(max)":                dal.String,
+		"super":                       dal.String,
+		"timestamp without time zone": dal.DateTime,
+		"timestamptz":                 dal.DateTime,
+		"date":                        dal.DateTime,
+	}
+	for raw, expectation := range cases {
+		t.Run(raw, func(t *testing.T) {
+			assert.Equal(t, expectation, client.MapType(raw))
+		})
+	}
+}
+
+func TestRedshiftFold(t *testing.T) {
+	client := warehouse.NewRedshift(warehouse.PostgresCredentials{})
+	assert.Equal(t, "customer_id", warehouse.Fold(client.Dialect(), "Customer_ID"))
+}
@@ -0,0 +1,119 @@
+package warehouse
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/mitchellh/mapstructure"
+	"github.com/supasheet/dal/pkg/dal"
+)
+
+var (
+	ErrUnsupportedAdapter = errors.New("unsupported adapter")
+
+	adaptersMu sync.RWMutex
+	adapters   = make(map[string]Adapter)
+)
+
+// Builds a warehouse client from a target's raw output in profiles.yml.
+type Factory func(output map[string]any) (Client, error)
+
+// An adapter is everything dal needs to know to talk to a particular kind of
+// data warehouse. The name it is registered under matches the dbt adapter
+// type, i.e. the `type` of a target in profiles.yml.
+type Adapter struct {
+	// Builds the client.
+	New Factory
+	// The goqu dialect used to generate SQL for this warehouse. It's
+	// registered with goqu under the adapter's name, so clients should return
+	// that name from Dialect.
+	Dialect *goqu.SQLDialectOptions
+	// Folds an identifier the way the warehouse folds unquoted identifiers,
+	// e.g. snowflake upper cases them. Generated SQL always quotes
+	// identifiers, so this gives the name a model or column created without
+	// quotes really has. Nil means identifiers are used as they are.
+	Fold func(string) string
+	// The view that lists a relation's columns, with the columns table_schema,
+	// table_name, column_name and data_type, folded. Nil means the
+	// information_schema.columns view of the relation's database, which is
+	// where most warehouses keep it.
+	InformationSchema func(rel dal.Relation, fold func(string) string) exp.Expression
+}
+
+// Registers an adapter. This is intended to be called from an init function,
+// so that adding an adapter to the build is all it takes to make it available.
+// It panics if an adapter is registered twice under the same name.
+func Register(name string, a Adapter) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+
+	if a.New == nil {
+		panic("warehouse: Register factory is nil for adapter " + name)
+	}
+	if _, dup := adapters[name]; dup {
+		panic("warehouse: Register called twice for adapter " + name)
+	}
+	adapters[name] = a
+
+	if a.Dialect != nil {
+		goqu.RegisterDialect(name, a.Dialect)
+	}
+}
+
+// Returns the names of all the registered adapters, sorted.
+func Adapters() []string {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+
+	var names []string
+	for name := range adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builds a client for the named adapter from a target's raw output.
+func New(adapter string, output map[string]any) (Client, error) {
+	adaptersMu.RLock()
+	a, ok := adapters[adapter]
+	adaptersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("warehouse type %s is not supported: %w", adapter, ErrUnsupportedAdapter)
+	}
+	return a.New(output)
+}
+
+// Folds an identifier using the rules of the adapter whose dialect is named.
+func Fold(dialect, id string) string {
+	adaptersMu.RLock()
+	a, ok := adapters[dialect]
+	adaptersMu.RUnlock()
+
+	if !ok || a.Fold == nil {
+		return id
+	}
+	return a.Fold(id)
+}
+
+// Decodes a target's raw output into a struct using its json tags. Input is
+// weakly typed as profile values aren't always the type you'd expect, e.g. a
+// port given as a string.
+func decodeOutput(output map[string]any, v any) error {
+	config := &mapstructure.DecoderConfig{
+		Metadata:         nil,
+		Result:           v,
+		TagName:          "json",
+		WeaklyTypedInput: true,
+	}
+	decoder, err := mapstructure.NewDecoder(config)
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(output)
+}
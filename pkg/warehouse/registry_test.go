@@ -0,0 +1,91 @@
+package warehouse_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+type fakeClient struct {
+	output map[string]any
+}
+
+func (fc *fakeClient) Connect() error { return nil }
+func (fc *fakeClient) Run(context.Context, string, ...any) (warehouse.Records, error) {
+	return nil, nil
+}
+func (fc *fakeClient) Stream(context.Context, string, ...any) (warehouse.Rows, error) {
+	return warehouse.FromRecords(nil), nil
+}
+func (fc *fakeClient) MapType(string) dal.Scalar { return dal.String }
+func (fc *fakeClient) Dialect() string           { return "fake" }
+
+func init() {
+	opts := goqu.DefaultDialectOptions()
+	opts.QuoteRune = '|'
+	warehouse.Register("fake", warehouse.Adapter{
+		New: func(output map[string]any) (warehouse.Client, error) {
+			return &fakeClient{output: output}, nil
+		},
+		Dialect: opts,
+	})
+}
+
+func TestRegistry_Builtins(t *testing.T) {
+	assert.Equal(t,
+		[]string{"bigquery", "duckdb", "fake", "postgres", "redshift", "snowflake"},
+		warehouse.Adapters(),
+	)
+}
+
+func TestRegistry_Unsupported(t *testing.T) {
+	_, err := warehouse.New("oracle", map[string]any{"type": "oracle"})
+	assert.ErrorIs(t, err, warehouse.ErrUnsupportedAdapter)
+}
+
+func TestRegistry_ThirdParty(t *testing.T) {
+	output := map[string]any{"type": "fake", "host": "localhost"}
+	client, err := warehouse.New("fake", output)
+	require.NoError(t, err)
+	assert.Equal(t, output, client.(*fakeClient).output)
+
+	// The adapter's dialect is available to goqu under the adapter's name.
+	sql, _, err := goqu.Dialect(client.Dialect()).From("foo").ToSQL()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM |foo|", sql)
+}
+
+func TestRegistry_Postgres(t *testing.T) {
+	client, err := warehouse.New("postgres", map[string]any{
+		"type":   "postgres",
+		"host":   "localhost",
+		"port":   "5432",
+		"user":   "user",
+		"pass":   "pw",
+		"dbname": "db",
+		"schema": "analytics",
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &warehouse.PostgresClient{}, client)
+	assert.Equal(t, "postgres", client.Dialect())
+}
+
+func TestRegistry_Fold(t *testing.T) {
+	assert.Equal(t, "ORDER_ID", warehouse.Fold("snowflake", "order_id"))
+	assert.Equal(t, "order_id", warehouse.Fold("postgres", "Order_ID"))
+	assert.Equal(t, "Order_ID", warehouse.Fold("bigquery", "Order_ID"))
+	assert.Equal(t, "Order_ID", warehouse.Fold("fake", "Order_ID"))
+}
+
+func TestRegistry_RegisterTwice(t *testing.T) {
+	assert.Panics(t, func() {
+		warehouse.Register("fake", warehouse.Adapter{
+			New: func(map[string]any) (warehouse.Client, error) { return nil, nil },
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package warehouse
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// An iterator over the results of a query. Rows are read one at a time, so
+// only the current row needs to be held in memory.
+//
+//	defer rows.Close()
+//	for rows.Next() {
+//		record := rows.Record()
+//		...
+//	}
+//	if err := rows.Err(); err != nil {
+//		...
+//	}
+type Rows interface {
+	// Advances to the next row. It returns false once the results are
+	// exhausted, or if an error occurs, which is then reported by Err.
+	Next() bool
+
+	// The current row. Each call to Next produces a new record, so it is safe
+	// to hold on to.
+	Record() Record
+
+	// The error, if any, that stopped iteration.
+	Err() error
+
+	// Releases the results. It's safe to call more than once.
+	Close() error
+}
+
+// Reads all of the remaining rows into memory and closes them.
+func Collect(rows Rows) (Records, error) {
+	defer rows.Close()
+
+	records := Records{}
+	for rows.Next() {
+		records = append(records, rows.Record())
+	}
+	if err := rows.Err(); err != nil {
+		return Records{}, err
+	}
+	return records, nil
+}
+
+// Iterates over records that are already in memory.
+func FromRecords(records Records) Rows {
+	return &recordRows{records: records, i: -1}
+}
+
+type recordRows struct {
+	records Records
+	i       int
+}
+
+func (rr *recordRows) Next() bool {
+	if rr.i+1 >= len(rr.records) {
+		return false
+	}
+	rr.i++
+	return true
+}
+
+func (rr *recordRows) Record() Record { return rr.records[rr.i] }
+func (rr *recordRows) Err() error     { return nil }
+func (rr *recordRows) Close() error   { return nil }
+
+// Calls release once the rows are closed, for rows that hold on to
+// something, like a connection.
+type releasingRows struct {
+	Rows
+	release  func() error
+	released bool
+}
+
+func (rr *releasingRows) Close() error {
+	err := rr.Rows.Close()
+	if !rr.released {
+		rr.released = true
+		if rerr := rr.release(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// Adapts database/sql rows.
+type sqlRows struct {
+	rs     *sql.Rows
+	cols   []string
+	vals   []any
+	ptrs   []any
+	record Record
+	err    error
+}
+
+func newSqlRows(rs *sql.Rows) (*sqlRows, error) {
+	// Get the columns from the result set
+	cols, err := rs.Columns()
+	if err != nil {
+		rs.Close()
+		return nil, err
+	}
+	// TODO: Lower casing the identifier names should probably not be
+	// handled here. The gql mapping layer is the bit htat cares about
+	// this and should force everything to be lowercase. Then again,
+	// it's an 'efficient' place to do it.
+	for i := 0; i < len(cols); i++ {
+		cols[i] = strings.ToLower(cols[i])
+	}
+
+	// We need a buffer to store values as we construct each record. We also
+	// need an array of pointers to each slot in that buffer, as that's how
+	// Scan works.
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+
+	return &sqlRows{rs: rs, cols: cols, vals: vals, ptrs: ptrs}, nil
+}
+
+func (sr *sqlRows) Next() bool {
+	if sr.err != nil || !sr.rs.Next() {
+		return false
+	}
+	// Scan the values
+	if err := sr.rs.Scan(sr.ptrs...); err != nil {
+		sr.err = err
+		return false
+	}
+	// Ok, we're ready to build the map for this record
+	sr.record = make(Record, len(sr.cols))
+	for i, c := range sr.cols {
+		sr.record[c] = sr.vals[i]
+	}
+	return true
+}
+
+func (sr *sqlRows) Record() Record { return sr.record }
+
+func (sr *sqlRows) Err() error {
+	if sr.err != nil {
+		return sr.err
+	}
+	return sr.rs.Err()
+}
+
+func (sr *sqlRows) Close() error { return sr.rs.Close() }
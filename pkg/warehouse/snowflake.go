@@ -0,0 +1,328 @@
+package warehouse
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/snowflakedb/gosnowflake"
+	"github.com/supasheet/dal/pkg/dal"
+	"github.com/youmark/pkcs8"
+)
+
+func init() {
+	// Snowflake upper cases unquoted identifiers.
+	Register("snowflake", Adapter{
+		New:     newSnowflakeFromOutput,
+		Dialect: goqu.DefaultDialectOptions(),
+		Fold:    strings.ToUpper,
+	})
+}
+
+type SnowflakeCredentials struct {
+	AccountId string `json:"account_id"`
+	User      string `json:"user"`
+	Password  string `json:"password"`
+	Database  string `json:"database"`
+	Schema    string `json:"schema"`
+	Warehouse string `json:"warehouse"`
+	Role      string `json:"role"`
+
+	// One of the dbt-snowflake authenticators: snowflake (the default),
+	// oauth, externalbrowser, or an okta URL. Key pair authentication is used
+	// whenever a private key is given.
+	Authenticator string `json:"authenticator"`
+	// The access token for oauth.
+	Token string `json:"token"`
+	// Key pair authentication. The key is either read from the path, or given
+	// inline as a PEM or base64 encoded DER key. The passphrase is only needed
+	// for encrypted keys.
+	PrivateKeyPath       string `json:"private_key_path"`
+	PrivateKey           string `json:"private_key"`
+	PrivateKeyPassphrase string `json:"private_key_passphrase"`
+
+	// Connection settings. Threads is the size of the connection pool.
+	// Connecting is retried ConnectRetries times, with backoff, but only on
+	// network errors unless RetryOnDatabaseErrors or RetryAll say otherwise.
+	// ConnectTimeout is in seconds.
+	Threads                int    `json:"threads"`
+	ConnectRetries         int    `json:"connect_retries"`
+	ConnectTimeout         int    `json:"connect_timeout"`
+	RetryOnDatabaseErrors  bool   `json:"retry_on_database_errors"`
+	RetryAll               bool   `json:"retry_all"`
+	ClientSessionKeepAlive bool   `json:"client_session_keep_alive"`
+	QueryTag               string `json:"query_tag"`
+}
+
+func (sfc SnowflakeCredentials) ConnString() (string, error) {
+	cfg := gosnowflake.Config{
+		Account:   sfc.AccountId,
+		User:      sfc.User,
+		Password:  sfc.Password,
+		Database:  sfc.Database,
+		Schema:    sfc.Schema,
+		Warehouse: sfc.Warehouse,
+		Role:      sfc.Role,
+		Token:     sfc.Token,
+		Params:    map[string]*string{},
+	}
+	if sfc.ConnectTimeout > 0 {
+		cfg.LoginTimeout = time.Duration(sfc.ConnectTimeout) * time.Second
+	}
+	if sfc.ClientSessionKeepAlive {
+		keepAlive := "true"
+		cfg.Params["client_session_keep_alive"] = &keepAlive
+	}
+	if sfc.QueryTag != "" {
+		queryTag := sfc.QueryTag
+		cfg.Params["query_tag"] = &queryTag
+	}
+
+	key, err := sfc.privateKey()
+	if err != nil {
+		return "", err
+	}
+	if key != nil {
+		cfg.Authenticator = gosnowflake.AuthTypeJwt
+		cfg.PrivateKey = key
+	} else if err := setSnowflakeAuthenticator(&cfg, sfc.Authenticator); err != nil {
+		return "", err
+	}
+
+	dsn, err := gosnowflake.DSN(&cfg)
+	if err != nil {
+		return "", err
+	}
+	return dsn, nil
+}
+
+// Loads the private key for key pair authentication, if there is one.
+func (sfc SnowflakeCredentials) privateKey() (*rsa.PrivateKey, error) {
+	var raw []byte
+	switch {
+	case sfc.PrivateKeyPath != "":
+		b, err := os.ReadFile(sfc.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snowflake private key: %w", err)
+		}
+		raw = b
+	case sfc.PrivateKey != "":
+		raw = []byte(sfc.PrivateKey)
+	default:
+		return nil, nil
+	}
+
+	var passphrase [][]byte
+	if sfc.PrivateKeyPassphrase != "" {
+		passphrase = append(passphrase, []byte(sfc.PrivateKeyPassphrase))
+	}
+
+	// Keys are usually PEM encoded, but dbt also accepts base64 encoded DER.
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+		if err != nil {
+			return nil, errors.New("snowflake private key is neither PEM nor base64 encoded")
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	var (
+		key *rsa.PrivateKey
+		err error
+	)
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		// Handles both plain and encrypted PKCS#8 keys
+		key, err = pkcs8.ParsePKCS8PrivateKeyRSA(block.Bytes, passphrase...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snowflake private key: %w", err)
+	}
+	return key, nil
+}
+
+// Maps a dbt-snowflake authenticator on to the driver's.
+func setSnowflakeAuthenticator(cfg *gosnowflake.Config, authenticator string) error {
+	switch strings.ToLower(authenticator) {
+	case "", "snowflake":
+		cfg.Authenticator = gosnowflake.AuthTypeSnowflake
+	case "oauth":
+		cfg.Authenticator = gosnowflake.AuthTypeOAuth
+	case "externalbrowser":
+		cfg.Authenticator = gosnowflake.AuthTypeExternalBrowser
+	case "snowflake_jwt":
+		return errors.New("snowflake_jwt authentication requires a private key")
+	default:
+		// Anything else should be an okta URL.
+		u, err := url.Parse(authenticator)
+		if err != nil || u.Scheme != "https" || !strings.HasSuffix(u.Host, "okta.com") {
+			return fmt.Errorf("snowflake authenticator %s is not supported", authenticator)
+		}
+		cfg.Authenticator = gosnowflake.AuthTypeOkta
+		cfg.OktaURL = u
+	}
+	return nil
+}
+
+type SnowflakeClient struct {
+	db    *sql.DB
+	creds SnowflakeCredentials
+}
+
+func NewSnowflake(sfc SnowflakeCredentials) *SnowflakeClient {
+	return &SnowflakeClient{creds: sfc}
+}
+
+func newSnowflakeFromOutput(output map[string]any) (Client, error) {
+	var sfc SnowflakeCredentials
+	if err := decodeOutput(output, &sfc); err != nil {
+		return nil, err
+	}
+	// dbt calls it account.
+	sfc.AccountId, _ = output["account"].(string)
+	return NewSnowflake(sfc), nil
+}
+
+func (sc *SnowflakeClient) Connect() error {
+	// Create the db instance
+	dsn, err := sc.creds.ConnString()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("snowflake", dsn)
+	if err != nil {
+		return err
+	}
+	// dbt runs this many queries at once, so it's a reasonable pool size.
+	if sc.creds.Threads > 0 {
+		db.SetMaxOpenConns(sc.creds.Threads)
+		db.SetMaxIdleConns(sc.creds.Threads)
+	}
+
+	// Configure the default snowflake logger, which is annoying.
+	sflog := gosnowflake.GetLogger()
+	// We basically don't want logs out of this. Everything relevant we get as
+	// an error message that we log ourselves.
+	sflog.SetLogLevel("panic")
+
+	// sql.Open doesn't actually connect, so make sure we can.
+	err = retry(sc.creds.ConnectRetries, sc.retryable, func() error {
+		return db.Ping()
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	sc.db = db
+	return nil
+}
+
+// Whether a failed connection attempt should be retried, following dbt.
+func (sc *SnowflakeClient) retryable(err error) bool {
+	if sc.creds.RetryAll {
+		return true
+	}
+	var sfErr *gosnowflake.SnowflakeError
+	if errors.As(err, &sfErr) {
+		return sc.creds.RetryOnDatabaseErrors
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (sc *SnowflakeClient) Run(ctx context.Context, query string, args ...any) (Records, error) {
+	rows, err := sc.Stream(ctx, query, args...)
+	if err != nil {
+		return Records{}, err
+	}
+	return Collect(rows)
+}
+
+// How long putting a connection's query tag back can take before the
+// connection is given up on.
+var queryTagResetTimeout = 10 * time.Second
+
+func (sc *SnowflakeClient) Stream(ctx context.Context, query string, args ...any) (Rows, error) {
+	tag, ok := QueryTag(ctx)
+	if !ok || sc.db == nil {
+		return streamQuery(ctx, sc.db, query, args)
+	}
+
+	// The query tag is a session parameter, so the query needs a connection
+	// of its own to set it on. It's put back to the profile's tag before the
+	// connection goes back in the pool.
+	if sc.creds.QueryTag != "" {
+		tag = sc.creds.QueryTag + ":" + tag
+	}
+	conn, err := sc.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release := func() error {
+		// The query's context may well be done by now, but a warehouse that
+		// doesn't answer shouldn't hold up closing the rows for long.
+		ctx, cancel := context.WithTimeout(context.Background(), queryTagResetTimeout)
+		defer cancel()
+		_, err := conn.ExecContext(ctx, "ALTER SESSION SET QUERY_TAG = "+snowflakeString(sc.creds.QueryTag))
+		if err != nil {
+			// Don't let the connection be reused with the wrong tag.
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		return conn.Close()
+	}
+
+	if _, err := conn.ExecContext(ctx, "ALTER SESSION SET QUERY_TAG = "+snowflakeString(tag)); err != nil {
+		release()
+		return nil, err
+	}
+	rs, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	rows, err := newSqlRows(rs)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &releasingRows{Rows: rows, release: release}, nil
+}
+
+// Renders a snowflake string literal.
+func snowflakeString(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	return "'" + strings.ReplaceAll(s, "'", "\\'") + "'"
+}
+
+func (sc *SnowflakeClient) MapType(t string) dal.Scalar {
+	return snowflakeDataTypeMatcher.match(t)
+}
+
+func (sc *SnowflakeClient) Dialect() string {
+	return "snowflake"
+}
+
+var snowflakeDataTypeMatcher = &dataTypeMatcher{
+	id:       regexp.MustCompile("a^"),
+	int:      regexp.MustCompile("(?i)(FIXED|INT|NUMBER|NUMERIC|DECIMAL)"),
+	float:    regexp.MustCompile("(?i)(REAL|FLOAT|DOUBLE)"),
+	boolean:  regexp.MustCompile("(?i)(BOOLEAN)"),
+	string:   regexp.MustCompile("(?i)(CHAR|STRING|TEXT)"),
+	dateTime: regexp.MustCompile("(?i)(TIME|DATE)"),
+}
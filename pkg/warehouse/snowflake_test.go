@@ -0,0 +1,343 @@
+package warehouse_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/pkg/warehouse"
+	"github.com/youmark/pkcs8"
+)
+
+func TestConnString(t *testing.T) {
+	type tc struct {
+		creds       warehouse.SnowflakeCredentials
+		expectation string
+	}
+	cases := []tc{
+		{
+			cred("acc.eu-west-1", "user", "pw", "db", "schema", "wh"),
+			"user:pw@acc.eu-west-1.snowflakecomputing.com:443?database=db&ocspFailOpen=true&region=eu-west-1&schema=schema&validateDefaultParameters=true&warehouse=wh",
+		},
+		{
+			cred("acc.eu-west-1", "user", "pw", "db", "schema", ""),
+			"user:pw@acc.eu-west-1.snowflakecomputing.com:443?database=db&ocspFailOpen=true&region=eu-west-1&schema=schema&validateDefaultParameters=true",
+		},
+		{
+			cred("acc.eu-west-1", "user", "pw", "db", "", "wh"),
+			"user:pw@acc.eu-west-1.snowflakecomputing.com:443?database=db&ocspFailOpen=true&region=eu-west-1&validateDefaultParameters=true&warehouse=wh",
+		},
+		{
+			cred("acc.eu-west-1", "user", "pw", "db", "", ""),
+			"user:pw@acc.eu-west-1.snowflakecomputing.com:443?database=db&ocspFailOpen=true&region=eu-west-1&validateDefaultParameters=true",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expectation, func(t *testing.T) {
+			dsn, err := c.creds.ConnString()
+			require.NoError(t, err)
+			assert.Equal(t, c.expectation, dsn)
+		})
+	}
+}
+
+func cred(accountId, user, pw, db, schema, wh string) warehouse.SnowflakeCredentials {
+	return warehouse.SnowflakeCredentials{
+		AccountId: accountId, User: user,
+		Password: pw, Database: db, Schema: schema, Warehouse: wh,
+	}
+}
+
+func TestConnString_Role(t *testing.T) {
+	c := cred("acc.eu-west-1", "user", "pw", "db", "", "")
+	c.Role = "transformer"
+	dsn, err := c.ConnString()
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "role=transformer")
+}
+
+func TestConnString_OAuth(t *testing.T) {
+	c := cred("acc.eu-west-1", "user", "", "db", "", "")
+	c.Authenticator = "oauth"
+	c.Token = "tok"
+	dsn, err := c.ConnString()
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "authenticator=oauth")
+	assert.Contains(t, dsn, "token=tok")
+}
+
+func TestConnString_KeyPair(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	plain, err := pkcs8.MarshalPrivateKey(key, nil, nil)
+	require.NoError(t, err)
+	encrypted, err := pkcs8.MarshalPrivateKey(key, []byte("secret"), nil)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "plain.p8")
+	require.NoError(t, os.WriteFile(plainPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: plain}), 0600))
+	encryptedPath := filepath.Join(dir, "encrypted.p8")
+	require.NoError(t, os.WriteFile(encryptedPath, pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted}), 0600))
+
+	type tc struct {
+		path, inline, passphrase string
+		err                      bool
+	}
+	cases := map[string]tc{
+		"path":             {path: plainPath},
+		"encrypted":        {path: encryptedPath, passphrase: "secret"},
+		"wrong_passphrase": {path: encryptedPath, passphrase: "wrong", err: true},
+		"inline_base64":    {inline: base64.StdEncoding.EncodeToString(plain)},
+		"inline_pem":       {inline: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: plain}))},
+		"missing":          {path: filepath.Join(dir, "missing.p8"), err: true},
+		"not_a_key":        {inline: "not a key!", err: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			creds := cred("acc.eu-west-1", "user", "", "db", "", "")
+			creds.PrivateKeyPath = c.path
+			creds.PrivateKey = c.inline
+			creds.PrivateKeyPassphrase = c.passphrase
+			dsn, err := creds.ConnString()
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, dsn, "authenticator=snowflake_jwt")
+			assert.Contains(t, dsn, "privateKey=")
+		})
+	}
+}
+
+func TestConnString_Authenticator(t *testing.T) {
+	cases := map[string]bool{
+		"snowflake":                        true,
+		"externalbrowser":                  true,
+		"https://example.okta.com":         true,
+		"snowflake_jwt":                    false,
+		"https://example.com/not-okta.com": false,
+	}
+	for authenticator, ok := range cases {
+		t.Run(authenticator, func(t *testing.T) {
+			c := cred("acc.eu-west-1", "user", "pw", "db", "", "")
+			c.Authenticator = authenticator
+			_, err := c.ConnString()
+			if ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestConnString_ConnectionSettings(t *testing.T) {
+	c := cred("acc.eu-west-1", "user", "pw", "db", "", "")
+	c.ConnectTimeout = 30
+	c.ClientSessionKeepAlive = true
+	c.QueryTag = "dal"
+	dsn, err := c.ConnString()
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "loginTimeout=30")
+	assert.Contains(t, dsn, "client_session_keep_alive=true")
+	assert.Contains(t, dsn, "query_tag=dal")
+}
+
+func TestNewSnowflakeFromOutput(t *testing.T) {
+	client, err := warehouse.New("snowflake", map[string]any{
+		"type":                      "snowflake",
+		"account":                   "acc.eu-west-1",
+		"user":                      "user",
+		"password":                  "pw",
+		"database":                  "db",
+		"threads":                   4,
+		"client_session_keep_alive": true,
+		"query_tag":                 "dal",
+	})
+	require.NoError(t, err)
+	require.IsType(t, &warehouse.SnowflakeClient{}, client)
+}
+
+func TestSnowflakeRetryable(t *testing.T) {
+	dbErr := fmt.Errorf("connecting: %w", &gosnowflake.SnowflakeError{Number: 390100})
+	netErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	otherErr := errors.New("bad config")
+
+	cases := []struct {
+		name     string
+		settings func(*warehouse.SnowflakeCredentials)
+		want     map[error]bool
+	}{
+		{
+			name:     "default",
+			settings: func(*warehouse.SnowflakeCredentials) {},
+			want:     map[error]bool{dbErr: false, netErr: true, otherErr: false},
+		},
+		{
+			name:     "retry_on_database_errors",
+			settings: func(c *warehouse.SnowflakeCredentials) { c.RetryOnDatabaseErrors = true },
+			want:     map[error]bool{dbErr: true, netErr: true, otherErr: false},
+		},
+		{
+			name:     "retry_all",
+			settings: func(c *warehouse.SnowflakeCredentials) { c.RetryAll = true },
+			want:     map[error]bool{dbErr: true, netErr: true, otherErr: true},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			creds := cred("acc", "user", "pw", "db", "", "")
+			c.settings(&creds)
+			client := warehouse.NewSnowflake(creds)
+			for err, want := range c.want {
+				assert.Equal(t, want, client.Retryable(err), err.Error())
+			}
+		})
+	}
+}
+
+func TestSnowflakeQueryTag(t *testing.T) {
+	db, fake := openFakeDB(t)
+	creds := cred("acc", "user", "pw", "db", "", "")
+	creds.QueryTag = "dal"
+	client := warehouse.NewSnowflake(creds)
+	client.SetDB(db)
+
+	ctx := warehouse.WithQueryTag(context.Background(), "Dashboard")
+	rows, err := client.Stream(ctx, "select 1")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	assert.Equal(t, []string{
+		"ALTER SESSION SET QUERY_TAG = 'dal:Dashboard'",
+		"select 1",
+		"ALTER SESSION SET QUERY_TAG = 'dal'",
+	}, fake.statements())
+
+	// Without a tag, the query runs as it is.
+	_, err = client.Run(context.Background(), "select 2")
+	require.NoError(t, err)
+	assert.Equal(t, "select 2", fake.statements()[3])
+}
+
+func TestSnowflakeQueryTag_ResetTimeout(t *testing.T) {
+	warehouse.SetQueryTagResetTimeout(t, 50*time.Millisecond)
+	db, fake := openFakeDB(t)
+	fake.hang = "ALTER SESSION SET QUERY_TAG = ''"
+	client := warehouse.NewSnowflake(cred("acc", "user", "pw", "db", "", ""))
+	client.SetDB(db)
+
+	rows, err := client.Stream(warehouse.WithQueryTag(context.Background(), "Dashboard"), "select 1")
+	require.NoError(t, err)
+
+	// A warehouse that doesn't answer doesn't hold up closing the rows, and
+	// the connection, still tagged, isn't reused.
+	done := make(chan struct{})
+	go func() {
+		rows.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the rows hung")
+	}
+	assert.Equal(t, 1, fake.closed())
+}
+
+// A database/sql driver that records the statements it's given and returns
+// no rows.
+type fakeDriver struct {
+	mu    sync.Mutex
+	stmts []string
+	// A statement that blocks until its context is done.
+	hang   string
+	closes int
+}
+
+var fakeDrivers sync.Map
+
+func openFakeDB(t *testing.T) (*sql.DB, *fakeDriver) {
+	fake := &fakeDriver{}
+	name := "fake-" + t.Name()
+	if _, loaded := fakeDrivers.LoadOrStore(name, fake); !loaded {
+		sql.Register(name, fake)
+	} else {
+		t.Fatalf("driver %s already registered", name)
+	}
+	db, err := sql.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, fake
+}
+
+func (fd *fakeDriver) statements() []string {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	return append([]string(nil), fd.stmts...)
+}
+
+func (fd *fakeDriver) closed() int {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	return fd.closes
+}
+
+func (fd *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{fd: fd}, nil }
+
+type fakeConn struct{ fd *fakeDriver }
+
+func (fc *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fc *fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (fc *fakeConn) Close() error {
+	fc.fd.mu.Lock()
+	defer fc.fd.mu.Unlock()
+	fc.fd.closes++
+	return nil
+}
+
+func (fc *fakeConn) record(ctx context.Context, query string) error {
+	fc.fd.mu.Lock()
+	fc.fd.stmts = append(fc.fd.stmts, query)
+	hang := strings.EqualFold(query, fc.fd.hang)
+	fc.fd.mu.Unlock()
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (fc *fakeConn) ExecContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), fc.record(ctx, query)
+}
+
+func (fc *fakeConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, fc.record(ctx, query)
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return []string{"n"} }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
@@ -0,0 +1,45 @@
+package e2e
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supasheet/dal/pkg/warehouse"
+)
+
+// Runs against the BigQuery emulator, e.g.
+// docker run -p 9050:9050 ghcr.io/goccy/bigquery-emulator --project=test
+func setupBigQuery(t *testing.T) warehouse.Client {
+	SkipE2E(t)
+
+	endpoint := os.Getenv("E2E_BIGQUERY_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("Set the E2E_BIGQUERY_ENDPOINT environment variable to run the BigQuery E2E tests.")
+	}
+
+	bc := warehouse.NewBigQuery(warehouse.BigQueryCredentials{
+		Project:     "test",
+		APIEndpoint: endpoint,
+	})
+
+	// Connect
+	err := bc.Connect()
+	require.NoError(t, err)
+
+	return bc
+}
+
+func TestBigQuery_Query(t *testing.T) {
+	client := setupBigQuery(t)
+	rs, err := client.Run(context.Background(), "select 1 as a, 2 as b")
+	require.NoError(t, err)
+
+	expectation := warehouse.Records{
+		warehouse.Record{"a": int64(1), "b": int64(2)},
+	}
+
+	assert.Equal(t, expectation, rs)
+}
@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"os"
@@ -8,7 +9,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/supasheet/dal/internal/warehouse"
+	"github.com/supasheet/dal/pkg/warehouse"
 )
 
 func setupSnowflake(t *testing.T) warehouse.Client {
@@ -33,7 +34,7 @@ func setupSnowflake(t *testing.T) warehouse.Client {
 
 func TestSnowflake_Query(t *testing.T) {
 	client := setupSnowflake(t)
-	rs, err := client.Run("select 1 as a, 2 as b;")
+	rs, err := client.Run(context.Background(), "select 1 as a, 2 as b;")
 	require.NoError(t, err)
 
 	expectation := warehouse.Records{
@@ -45,7 +46,7 @@ func TestSnowflake_Query(t *testing.T) {
 
 func TestSnowflake_QueryTwoRows(t *testing.T) {
 	client := setupSnowflake(t)
-	rs, err := client.Run("select 0 as a, 2 as b union select 1 as a, 3 as b;")
+	rs, err := client.Run(context.Background(), "select 0 as a, 2 as b union select 1 as a, 3 as b;")
 
 	require.NoError(t, err)
 
@@ -60,6 +61,7 @@ func TestSnowflake_QueryTwoRows(t *testing.T) {
 func TestSnowflake_QueryMultipleRows(t *testing.T) {
 	client := setupSnowflake(t)
 	rs, err := client.Run(
+		context.Background(),
 		"select 0 as a, 4 as b union select 1 as a, 5 as b union select 2 as a, 6 as b union select 3 as a, 7 as b;",
 	)
 	require.NoError(t, err)